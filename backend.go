@@ -0,0 +1,326 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// packageBackend is the system package manager behind the system step
+type packageBackend interface {
+	// name identifies the step in the reports, the journal, the hooks and the metrics
+	name() string
+	// command is the program that must be installed
+	command() string
+	// refresh downloads the package lists and returns the output of the package manager
+	refresh() (string, error)
+	// upgradable lists the packages to upgrade, one display line each
+	upgradable() ([]string, error)
+	// packageName returns the package of an upgradable line
+	packageName(line string) string
+	// countSecurity counts the security updates among the upgradable lines
+	countSecurity(lines []string) (int, error)
+	// upgrade installs the updates, with the distribution upgrade when full is set
+	upgrade(full bool) error
+	// cleanup removes the orphan packages and cleans the cache
+	cleanup()
+	// rebootRequired tells whether the installed updates need a reboot
+	rebootRequired() bool
+}
+
+// systemBackend returns the package manager of the distribution, driven on
+// Debian and Ubuntu by the APT frontend of the configuration.
+// An unknown distribution is identified by its installed package manager.
+func systemBackend(distro Distro, aptFrontend string) packageBackend {
+	switch {
+	case distro.is("fedora", "rhel", "centos"):
+		return dnfBackend{}
+	case distro.is("arch"):
+		return pacmanBackend{}
+	case distro.is("debian", "ubuntu"):
+		return aptBackend{frontend: aptFrontend, upgradeCmd: distro.upgradeCommand()}
+	case !commandExists("apt") && commandExists("dnf"):
+		return dnfBackend{}
+	case !commandExists("apt") && commandExists("pacman"):
+		return pacmanBackend{}
+	}
+	return aptBackend{frontend: aptFrontend, upgradeCmd: distro.upgradeCommand()}
+}
+
+// splitLines returns the non-empty trimmed lines of a command output
+func splitLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// aptBackend drives APT on Debian, Ubuntu and their derivatives
+type aptBackend struct {
+	frontend string
+	// Tool of the distribution replacing the upgrade of the frontend, nil for none
+	upgradeCmd []string
+}
+
+// phases returns the invocations of the frontend, apt when unknown
+func (b aptBackend) phases() aptFrontend {
+	if f, ok := aptFrontends[b.frontend]; ok {
+		return f
+	}
+	return aptFrontends[defaultAptFrontend]
+}
+
+func (aptBackend) name() string { return "apt" }
+
+func (b aptBackend) command() string { return b.phases().update[0] }
+
+// run runs a phase of the frontend with sudo, nothing when it has no equivalent
+func (b aptBackend) run(phase []string) error {
+	if phase == nil {
+		return nil
+	}
+	_, err := runCommand("sudo", phase...)
+	return err
+}
+
+// refresh returns the output of the update, read by the repository check
+func (b aptBackend) refresh() (string, error) {
+	update := b.phases().update
+	return runCommand("sudo", update...)
+}
+
+func (b aptBackend) upgradable() ([]string, error) {
+	f := b.phases()
+	output, err := runCommand(f.list[0], f.list[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return f.parse(output), nil
+}
+
+func (aptBackend) packageName(line string) string { return packageName(line) }
+
+func (aptBackend) countSecurity(lines []string) (int, error) {
+	count := 0
+	for _, line := range lines {
+		if isSecurityUpdate(line) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (b aptBackend) upgrade(full bool) error {
+	f := b.phases()
+	if b.upgradeCmd != nil {
+		printMessage(Blue, getMessage("distro_upgrade_tool", b.upgradeCmd[1]))
+		if _, err := runCommand(b.upgradeCmd[0], b.upgradeCmd[1:]...); err != nil {
+			return err
+		}
+	} else if err := b.run(f.upgrade); err != nil {
+		return err
+	}
+
+	if full {
+		// Distribution upgrade
+		printMessage(Blue, getMessage("dist_upgrade"))
+		if err := b.run(f.fullUpgrade); err != nil {
+			printMessage(Yellow, getMessage("dist_error"))
+		}
+	}
+	return nil
+}
+
+func (b aptBackend) cleanup() {
+	f := b.phases()
+	// Clean up obsolete packages
+	if f.autoremove != nil {
+		printMessage(Blue, getMessage("removing_obsolete"))
+		if err := b.run(f.autoremove); err != nil {
+			printMessage(Yellow, getMessage("autoremove_error"))
+		}
+	}
+
+	// Cache cleanup
+	printMessage(Blue, getMessage("cleaning_cache"))
+	if err := b.run(f.clean); err != nil {
+		printMessage(Yellow, getMessage("autoclean_error"))
+	}
+}
+
+func (aptBackend) rebootRequired() bool {
+	_, err := os.Stat("/var/run/reboot-required")
+	return err == nil
+}
+
+// dnfBackend drives dnf on Fedora, RHEL and their derivatives
+type dnfBackend struct{}
+
+func (dnfBackend) name() string { return "dnf" }
+
+func (dnfBackend) command() string { return "dnf" }
+
+func (dnfBackend) refresh() (string, error) {
+	return runCommand("sudo", "dnf", "makecache", "--refresh", "-q")
+}
+
+func (dnfBackend) upgradable() ([]string, error) {
+	output, err := runCommand("dnf", "check-update", "-q")
+	// Exit status 100: updates are available
+	if err != nil && exitCode(err) != 100 {
+		return nil, err
+	}
+	return parseDnfCheckUpdate(output), nil
+}
+
+// parseDnfCheckUpdate extracts the package lines of "dnf check-update":
+// "name.arch  version  repository", followed by an optional obsoletes section
+func parseDnfCheckUpdate(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		// Continuation lines of the obsoleted packages are indented
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "Obsoleting") || strings.HasPrefix(line, "Security:") {
+			break
+		}
+		if len(strings.Fields(line)) == 3 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func (dnfBackend) packageName(line string) string {
+	name := strings.Fields(line)[0]
+	// Strip the architecture
+	if i := strings.LastIndex(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+func (dnfBackend) countSecurity([]string) (int, error) {
+	output, err := runCommand("dnf", "updateinfo", "list", "--security", "-q")
+	if err != nil {
+		return 0, err
+	}
+	// One advisory per line, a package may appear in several advisories
+	packages := make(map[string]bool)
+	for _, line := range splitLines(output) {
+		if fields := strings.Fields(line); len(fields) >= 3 {
+			packages[fields[len(fields)-1]] = true
+		}
+	}
+	return len(packages), nil
+}
+
+func (dnfBackend) upgrade(full bool) error {
+	if full {
+		// Synchronizes with the repositories, downgrading when needed
+		printMessage(Blue, getMessage("dist_upgrade"))
+		_, err := runCommand("sudo", "dnf", "distro-sync", "-y")
+		return err
+	}
+	_, err := runCommand("sudo", "dnf", "upgrade", "-y")
+	return err
+}
+
+func (dnfBackend) cleanup() {
+	printMessage(Blue, getMessage("removing_obsolete"))
+	if _, err := runCommand("sudo", "dnf", "autoremove", "-y"); err != nil {
+		printMessage(Yellow, getMessage("autoremove_error"))
+	}
+
+	printMessage(Blue, getMessage("cleaning_cache"))
+	if _, err := runCommand("sudo", "dnf", "clean", "packages"); err != nil {
+		printMessage(Yellow, getMessage("autoclean_error"))
+	}
+}
+
+func (dnfBackend) rebootRequired() bool {
+	// Exit status 1: the kernel or core libraries were updated
+	_, err := runCommand("dnf", "needs-restarting", "-r")
+	return exitCode(err) == 1
+}
+
+// pacmanBackend drives pacman on Arch Linux and its derivatives
+type pacmanBackend struct{}
+
+func (pacmanBackend) name() string { return "pacman" }
+
+func (pacmanBackend) command() string { return "pacman" }
+
+// refresh is left to "pacman -Syu": refreshing the databases without upgrading
+// would leave a partial upgrade. checkupdates uses a copy of the databases.
+func (pacmanBackend) refresh() (string, error) { return "", nil }
+
+func (pacmanBackend) upgradable() ([]string, error) {
+	if !commandExists("checkupdates") {
+		return nil, errors.New(getMessage("pacman_checkupdates_missing"))
+	}
+	output, err := runCommand("checkupdates")
+	// Exit status 2: no updates
+	if err != nil && exitCode(err) != 2 {
+		return nil, err
+	}
+	return splitLines(output), nil
+}
+
+// packageName returns the package of a "checkupdates" line: "name old -> new"
+func (pacmanBackend) packageName(line string) string { return strings.Fields(line)[0] }
+
+// countSecurity returns 0: pacman has no security metadata
+func (pacmanBackend) countSecurity([]string) (int, error) { return 0, nil }
+
+func (pacmanBackend) upgrade(bool) error {
+	_, err := runCommand("sudo", "pacman", "-Syu", "--noconfirm")
+	return err
+}
+
+func (pacmanBackend) cleanup() {
+	printMessage(Blue, getMessage("removing_obsolete"))
+	// Exit status 1: no orphans
+	output, err := runCommand("pacman", "-Qdtq")
+	if orphans := splitLines(output); err == nil && len(orphans) > 0 {
+		args := append([]string{"pacman", "-Rns", "--noconfirm"}, orphans...)
+		if _, err := runCommand("sudo", args...); err != nil {
+			printMessage(Yellow, getMessage("autoremove_error"))
+		}
+	}
+
+	// paccache (pacman-contrib) keeps the last three versions of each package
+	if commandExists("paccache") {
+		printMessage(Blue, getMessage("cleaning_cache"))
+		if _, err := runCommand("sudo", "paccache", "-r"); err != nil {
+			printMessage(Yellow, getMessage("autoclean_error"))
+		}
+	}
+}
+
+// rebootRequired tells whether the running kernel was replaced:
+// its modules are removed when the kernel package is upgraded
+func (pacmanBackend) rebootRequired() bool {
+	var uts syscall.Utsname
+	if err := syscall.Uname(&uts); err != nil {
+		return false
+	}
+	var release strings.Builder
+	for _, c := range uts.Release {
+		if c == 0 {
+			break
+		}
+		release.WriteByte(byte(c))
+	}
+	_, err := os.Stat("/usr/lib/modules/" + release.String())
+	return os.IsNotExist(err)
+}
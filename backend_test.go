@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseDnfCheckUpdate(t *testing.T) {
+	output := `
+kernel.x86_64                     6.9.7-200.fc40          updates
+firefox.x86_64                    127.0.2-1.fc40          updates
+python3-libs.x86_64               3.12.4-1.fc40           updates
+Obsoleting Packages
+grub2-tools-efi.x86_64            1:2.06-121.fc40         updates
+    grub2-tools-efi.x86_64        1:2.06-120.fc40         @updates
+`
+	lines := parseDnfCheckUpdate(output)
+	if len(lines) != 3 {
+		t.Fatalf("%d lignes, attendu 3: %v", len(lines), lines)
+	}
+
+	var names []string
+	for _, line := range lines {
+		names = append(names, dnfBackend{}.packageName(line))
+	}
+	expected := []string{"kernel", "firefox", "python3-libs"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Paquets = %v, attendu %v", names, expected)
+	}
+
+	if lines := parseDnfCheckUpdate(""); len(lines) != 0 {
+		t.Errorf("Aucune mise à jour attendue, obtenu %v", lines)
+	}
+}
+
+func TestPacmanPackageName(t *testing.T) {
+	if got := (pacmanBackend{}).packageName("linux 6.9.6.arch1-1 -> 6.9.7.arch1-1"); got != "linux" {
+		t.Errorf("packageName() = %q, attendu %q", got, "linux")
+	}
+}
+
+func TestSystemBackend(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{ubuntuOSRelease, "apt"},
+		{mintOSRelease, "apt"},
+		{debianOSRelease, "apt"},
+		{fedoraOSRelease, "dnf"},
+		{"ID=rocky\nID_LIKE=\"rhel centos fedora\"\n", "dnf"},
+		{"ID=arch\n", "pacman"},
+		{"ID=endeavouros\nID_LIKE=arch\n", "pacman"},
+	}
+
+	for _, tt := range tests {
+		d := parseOSRelease(tt.content)
+		if got := systemBackend(d, defaultAptFrontend).name(); got != tt.expected {
+			t.Errorf("systemBackend(%s) = %s, attendu %s", d.ID, got, tt.expected)
+		}
+	}
+}
+
+// fakeBackend records the calls of updateSystem
+type fakeBackend struct {
+	lines      []string
+	output     string
+	upgradeErr error
+	upgraded   *bool
+	full       *bool
+}
+
+func (fakeBackend) name() string                        { return "fake" }
+func (fakeBackend) command() string                     { return "true" }
+func (b fakeBackend) refresh() (string, error)          { return b.output, nil }
+func (b fakeBackend) upgradable() ([]string, error)     { return b.lines, nil }
+func (fakeBackend) packageName(line string) string      { return "pkg-" + line }
+func (fakeBackend) countSecurity([]string) (int, error) { return 0, nil }
+func (fakeBackend) cleanup()                            {}
+func (fakeBackend) rebootRequired() bool                { return false }
+func (b fakeBackend) upgrade(full bool) error {
+	*b.upgraded, *b.full = true, full
+	return b.upgradeErr
+}
+
+func TestUpdateSystem_Backend(t *testing.T) {
+	var upgraded, full bool
+	b := fakeBackend{lines: []string{"a", "b"}, upgraded: &upgraded, full: &full}
+	outcome, err := updateSystem(b, true, ChangelogOff)
+	if err != nil {
+		t.Fatalf("Erreur inattendue: %v", err)
+	}
+	if !upgraded || !full {
+		t.Errorf("upgrade(true) aurait dû être appelé: appelé=%v full=%v", upgraded, full)
+	}
+	if !reflect.DeepEqual(outcome.upgraded, []string{"pkg-a", "pkg-b"}) {
+		t.Errorf("Paquets = %v", outcome.upgraded)
+	}
+
+	upgraded = false
+	b.lines = nil
+	b.output = "Hit:1 http://archive.ubuntu.com/ubuntu noble InRelease"
+	outcome, err = updateSystem(b, false, ChangelogOff)
+	if err != nil || upgraded {
+		t.Errorf("Sans mise à jour, upgrade ne devrait pas être appelé (err=%v)", err)
+	}
+	// Kept for the repository check, packages to upgrade or not
+	if outcome.refreshOutput != b.output {
+		t.Errorf("Sortie du rafraîchissement = %q, attendu %q", outcome.refreshOutput, b.output)
+	}
+
+	b.lines = []string{"a"}
+	b.upgradeErr = errors.New("dnf failed")
+	if outcome, err := updateSystem(b, false, ChangelogOff); err == nil || outcome.upgraded != nil {
+		t.Errorf("L'échec de upgrade devrait être renvoyé: %v, %v", outcome.upgraded, err)
+	}
+}
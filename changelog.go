@@ -0,0 +1,166 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Modes of the changelog preview
+const (
+	ChangelogOff       = "off"       // no preview
+	ChangelogAll       = "all"       // every entry newer than the installed version
+	ChangelogImportant = "important" // only the urgent entries and those fixing CVEs
+)
+
+// Maximum entries read when the installed version is not found in the changelog
+const maxChangelogEntries = 20
+
+// ChangelogEntry is an entry of a Debian changelog
+type ChangelogEntry struct {
+	Version string   `json:"version"`
+	Urgency string   `json:"urgency"`
+	CVEs    []string `json:"cves,omitempty"`
+	Text    string   `json:"text"`
+}
+
+// PackageChangelog lists the changelog entries of an upgrade, newest first
+type PackageChangelog struct {
+	Package string           `json:"package"`
+	From    string           `json:"from"`
+	To      string           `json:"to"`
+	Entries []ChangelogEntry `json:"entries"`
+}
+
+// validChangelogMode tells whether mode is a known changelog mode
+func validChangelogMode(mode string) bool {
+	switch mode {
+	case ChangelogOff, ChangelogAll, ChangelogImportant:
+		return true
+	}
+	return false
+}
+
+var (
+	// "openssl (3.0.13-0ubuntu3.2) noble-security; urgency=medium"
+	changelogHeader = regexp.MustCompile(`^(\S+) \(([^)]+)\) [^;]+;.*\burgency=(\w+)`)
+	cvePattern      = regexp.MustCompile(`CVE-\d{4}-\d{4,}`)
+	// "[upgradable from: 3.0.13-0ubuntu3.1]"
+	upgradableFrom = regexp.MustCompile(`\[upgradable from: ([^\]]+)\]`)
+)
+
+// parseDebianChangelog returns the entries newer than the installed version
+func parseDebianChangelog(output, installed string) []ChangelogEntry {
+	var entries []ChangelogEntry
+	var current *ChangelogEntry
+	var text []string
+	flush := func() {
+		if current != nil {
+			current.Text = strings.TrimSpace(strings.Join(text, "\n"))
+			current.CVEs = uniqueCVEs(current.Text)
+			entries = append(entries, *current)
+		}
+		current, text = nil, nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if m := changelogHeader.FindStringSubmatch(line); m != nil {
+			flush()
+			if m[2] == installed || len(entries) >= maxChangelogEntries {
+				break
+			}
+			current = &ChangelogEntry{Version: m[2], Urgency: strings.ToLower(m[3])}
+			continue
+		}
+		// The trailer line " -- Maintainer <email>  date" ends the entry
+		if current == nil || strings.HasPrefix(line, " -- ") {
+			continue
+		}
+		text = append(text, strings.TrimRight(line, " "))
+	}
+	flush()
+	return entries
+}
+
+// uniqueCVEs returns the CVE identifiers mentioned in text, in order
+func uniqueCVEs(text string) []string {
+	var cves []string
+	seen := make(map[string]bool)
+	for _, cve := range cvePattern.FindAllString(text, -1) {
+		if !seen[cve] {
+			seen[cve] = true
+			cves = append(cves, cve)
+		}
+	}
+	return cves
+}
+
+// importantEntry tells whether an entry is urgent or fixes a CVE
+func importantEntry(e ChangelogEntry) bool {
+	switch e.Urgency {
+	case "high", "emergency", "critical":
+		return true
+	}
+	return len(e.CVEs) > 0
+}
+
+// filterChangelog keeps the entries shown in mode
+func filterChangelog(entries []ChangelogEntry, mode string) []ChangelogEntry {
+	if mode != ChangelogImportant {
+		return entries
+	}
+	var kept []ChangelogEntry
+	for _, e := range entries {
+		if importantEntry(e) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// upgradeVersions returns the candidate and installed versions of an "apt list --upgradable" line
+func upgradeVersions(line string) (to, from string) {
+	if fields := strings.Fields(line); len(fields) > 1 {
+		to = fields[1]
+	}
+	if m := upgradableFrom.FindStringSubmatch(line); m != nil {
+		from = m[1]
+	}
+	return to, from
+}
+
+// previewChangelogs fetches with "apt changelog" the entries of the upgradable
+// packages newer than their installed version, shows those kept by mode and
+// returns them. A changelog that cannot be fetched is only reported.
+func previewChangelogs(b packageBackend, lines []string, mode string) []PackageChangelog {
+	printMessage(Blue, getMessage("changelog_fetching"))
+	var changelogs []PackageChangelog
+	for _, line := range lines {
+		name := b.packageName(line)
+		to, from := upgradeVersions(line)
+		output, err := runCommand("apt", "changelog", name)
+		if err != nil {
+			printMessage(Yellow, getMessage("changelog_error", name))
+			continue
+		}
+		entries := filterChangelog(parseDebianChangelog(output, from), mode)
+		if len(entries) == 0 {
+			continue
+		}
+		changelogs = append(changelogs, PackageChangelog{Package: name, From: from, To: to, Entries: entries})
+	}
+
+	for _, c := range changelogs {
+		printMessage(Yellow, getMessage("changelog_package", c.Package, c.From, c.To))
+		for _, e := range c.Entries {
+			printMessage("", getMessage("changelog_entry", e.Version, e.Urgency))
+			for _, line := range strings.Split(e.Text, "\n") {
+				printMessage("", "  "+line)
+			}
+		}
+		printSeparator()
+	}
+	if len(changelogs) == 0 {
+		printMessage(Green, getMessage("changelog_none"))
+	}
+	return changelogs
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const opensslChangelog = `openssl (3.0.13-0ubuntu3.4) noble-security; urgency=medium
+
+  * SECURITY UPDATE: Use-after-free in SSL_free_buffers
+    - debian/patches/CVE-2024-4741.patch: do not free a buffer in use.
+    - CVE-2024-4741
+  * SECURITY UPDATE: Denial of service in DSA key checks
+    - CVE-2024-4603
+
+ -- Security Team <security@ubuntu.com>  Mon, 05 Aug 2024 10:00:00 -0400
+
+openssl (3.0.13-0ubuntu3.3) noble; urgency=high
+
+  * Fix a crash of the TLS 1.3 handshake
+
+ -- Maintainer <maint@ubuntu.com>  Thu, 01 Aug 2024 10:00:00 -0400
+
+openssl (3.0.13-0ubuntu3.2) noble; urgency=low
+
+  * Documentation fixes
+
+ -- Maintainer <maint@ubuntu.com>  Mon, 01 Jul 2024 10:00:00 -0400
+
+openssl (3.0.13-0ubuntu3.1) noble; urgency=medium
+
+  * Installed version
+
+ -- Maintainer <maint@ubuntu.com>  Mon, 01 Jun 2024 10:00:00 -0400
+`
+
+func TestParseDebianChangelog(t *testing.T) {
+	entries := parseDebianChangelog(opensslChangelog, "3.0.13-0ubuntu3.1")
+	if len(entries) != 3 {
+		t.Fatalf("parseDebianChangelog() = %d entrées, attendu 3: %+v", len(entries), entries)
+	}
+
+	first := entries[0]
+	if first.Version != "3.0.13-0ubuntu3.4" || first.Urgency != "medium" {
+		t.Errorf("Première entrée: %+v", first)
+	}
+	if !reflect.DeepEqual(first.CVEs, []string{"CVE-2024-4741", "CVE-2024-4603"}) {
+		t.Errorf("CVEs = %v, attendu CVE-2024-4741 et CVE-2024-4603", first.CVEs)
+	}
+	if entries[1].Text != "* Fix a crash of the TLS 1.3 handshake" {
+		t.Errorf("Texte = %q", entries[1].Text)
+	}
+
+	// Installed version unknown: every entry, up to the limit
+	if got := parseDebianChangelog(opensslChangelog, ""); len(got) != 4 {
+		t.Errorf("Version installée inconnue: %d entrées, attendu 4", len(got))
+	}
+}
+
+func TestFilterChangelog(t *testing.T) {
+	entries := parseDebianChangelog(opensslChangelog, "3.0.13-0ubuntu3.1")
+
+	if got := filterChangelog(entries, ChangelogAll); len(got) != 3 {
+		t.Errorf("Mode all: %d entrées, attendu 3", len(got))
+	}
+	important := filterChangelog(entries, ChangelogImportant)
+	if len(important) != 2 || important[0].Version != "3.0.13-0ubuntu3.4" || important[1].Version != "3.0.13-0ubuntu3.3" {
+		t.Errorf("Mode important: %+v, attendu les entrées CVE et urgency=high", important)
+	}
+}
+
+func TestUpgradeVersions(t *testing.T) {
+	to, from := upgradeVersions("openssl/noble-security 3.0.13-0ubuntu3.4 amd64 [upgradable from: 3.0.13-0ubuntu3.1]")
+	if to != "3.0.13-0ubuntu3.4" || from != "3.0.13-0ubuntu3.1" {
+		t.Errorf("upgradeVersions() = %q, %q", to, from)
+	}
+}
+
+func TestValidChangelogMode(t *testing.T) {
+	for mode, expected := range map[string]bool{"off": true, "all": true, "important": true, "major": false, "": false} {
+		if got := validChangelogMode(mode); got != expected {
+			t.Errorf("validChangelogMode(%q) = %v, attendu %v", mode, got, expected)
+		}
+	}
+}
+
+// changelogFake is an updater returning changelogs with its upgraded packages
+type changelogFake struct{ cleaned *bool }
+
+func (changelogFake) Name() string               { return "fake" }
+func (changelogFake) Detect() bool               { return true }
+func (changelogFake) Pending() ([]string, error) { return nil, nil }
+func (changelogFake) Update() ([]string, error)  { return []string{"openssl"}, nil }
+func (f changelogFake) Cleanup() error           { *f.cleaned = true; return nil }
+func (changelogFake) UpdateOutcome() (updateOutcome, error) {
+	return updateOutcome{
+		upgraded:   []string{"openssl"},
+		changelogs: []PackageChangelog{{Package: "openssl", To: "3.0.13-0ubuntu3.4"}},
+	}, nil
+}
+
+func TestRunUpdater_Changelogs(t *testing.T) {
+	var cleaned bool
+	outcome, err := runUpdater(changelogFake{&cleaned})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(outcome.upgraded, []string{"openssl"}) || len(outcome.changelogs) != 1 || outcome.changelogs[0].Package != "openssl" {
+		t.Errorf("runUpdater() = %+v, attendu openssl et son changelog", outcome)
+	}
+	if !cleaned {
+		t.Error("Cleanup() aurait dû être appelé")
+	}
+}
+
+func TestPreviewChangelogs_Quiet(t *testing.T) {
+	if !commandExists("sh") {
+		t.Skip("sh non installé")
+	}
+	// Fake "apt changelog"
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncat <<'EOF'\n" + opensslChangelog + "EOF\n"
+	if err := os.WriteFile(filepath.Join(dir, "apt"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	defer func() { verbosity = LevelNormal }()
+	verbosity = LevelQuiet
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	changelogs := previewChangelogs(aptBackend{frontend: "apt"},
+		[]string{"openssl/noble-security 3.0.13-0ubuntu3.4 amd64 [upgradable from: 3.0.13-0ubuntu3.1]"}, ChangelogAll)
+	os.Stdout = stdout
+	w.Close()
+
+	if len(changelogs) != 1 || len(changelogs[0].Entries) != 3 {
+		t.Fatalf("previewChangelogs() = %+v, attendu 3 entrées d'openssl", changelogs)
+	}
+	// Only the warnings are shown in quiet mode, not the entries
+	output, _ := io.ReadAll(r)
+	if strings.Contains(string(output), "CVE-2024-4741") {
+		t.Errorf("Entrées affichées en mode silencieux:\n%s", output)
+	}
+}
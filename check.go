@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Nagios plugin exit codes
+const (
+	CheckOK       = 0
+	CheckWarning  = 1
+	CheckCritical = 2
+	CheckUnknown  = 3
+)
+
+// CheckConfig holds the thresholds of the check subcommand.
+// A state is reached when a count is greater than or equal to its threshold, 0 disables it.
+type CheckConfig struct {
+	WarningPending   int `json:"warning_pending"`
+	CriticalPending  int `json:"critical_pending"`
+	WarningSecurity  int `json:"warning_security"`
+	CriticalSecurity int `json:"critical_security"`
+	// State when a reboot is required: ok, warning or critical
+	Reboot string `json:"reboot"`
+}
+
+// defaultCheckConfig warns on any pending update and goes critical on security updates
+func defaultCheckConfig() CheckConfig {
+	return CheckConfig{
+		WarningPending:   1,
+		CriticalPending:  0,
+		WarningSecurity:  0,
+		CriticalSecurity: 1,
+		Reboot:           "warning",
+	}
+}
+
+// stateName returns the Nagios name of a state
+func stateName(state int) string {
+	switch state {
+	case CheckOK:
+		return "OK"
+	case CheckWarning:
+		return "WARNING"
+	case CheckCritical:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+// overThreshold tells whether count reaches an enabled threshold
+func overThreshold(count, threshold int) bool {
+	return threshold > 0 && count >= threshold
+}
+
+// checkState computes the state of the system from the pending updates
+func checkState(p PendingUpdates, reboot bool, c CheckConfig) int {
+	state := CheckOK
+	raise := func(s int) {
+		if s > state {
+			state = s
+		}
+	}
+
+	if overThreshold(p.Total(), c.WarningPending) || overThreshold(p.APTSecurity, c.WarningSecurity) {
+		raise(CheckWarning)
+	}
+	if overThreshold(p.Total(), c.CriticalPending) || overThreshold(p.APTSecurity, c.CriticalSecurity) {
+		raise(CheckCritical)
+	}
+	if reboot {
+		switch c.Reboot {
+		case "critical":
+			raise(CheckCritical)
+		case "ok":
+		default:
+			raise(CheckWarning)
+		}
+	}
+	return state
+}
+
+// perfData formats the counts as Nagios performance data
+func perfData(p PendingUpdates, reboot bool, c CheckConfig) string {
+	return fmt.Sprintf("pending=%d;%d;%d security=%d;%d;%d snap=%d flatpak=%d reboot=%d",
+		p.Total(), c.WarningPending, c.CriticalPending,
+		p.APTSecurity, c.WarningSecurity, c.CriticalSecurity,
+		p.Snap, p.Flatpak, int(boolValue(reboot)))
+}
+
+// setupCheck defines the check command
+func setupCheck(fs *flag.FlagSet, config *Config) func([]string) int {
+	refresh := fs.Bool("refresh", false, getMessage("flag_refresh"))
+	writeMetricsFile := fs.Bool("metrics", false, getMessage("flag_metrics"))
+	fs.IntVar(&config.Check.WarningPending, "warning", config.Check.WarningPending, getMessage("flag_warning"))
+	fs.IntVar(&config.Check.CriticalPending, "critical", config.Check.CriticalPending, getMessage("flag_critical"))
+	fs.IntVar(&config.Check.WarningSecurity, "security-warning", config.Check.WarningSecurity, getMessage("flag_security_warning"))
+	fs.IntVar(&config.Check.CriticalSecurity, "security-critical", config.Check.CriticalSecurity, getMessage("flag_security_critical"))
+	fs.StringVar(&config.Check.Reboot, "reboot", config.Check.Reboot, getMessage("flag_reboot_state"))
+
+	return func([]string) int {
+		return runCheck(*config, *refresh, *writeMetricsFile)
+	}
+}
+
+// runCheck reports the pending updates without touching the system
+// and returns a Nagios exit code
+func runCheck(config Config, refresh, writeMetricsFile bool) int {
+	thresholds := config.Check
+
+	// Optional refresh of the package lists
+	if refresh {
+		if _, err := systemBackend(detectDistro(), config.AptFrontend).refresh(); err != nil {
+			fmt.Printf("UNKNOWN - %s\n", getMessage("update_error"))
+			return CheckUnknown
+		}
+	}
+
+	pending, err := countPending(config)
+	if err != nil {
+		fmt.Printf("UNKNOWN - %s\n", getMessage("check_packages"))
+		return CheckUnknown
+	}
+	reboot := rebootRequired()
+
+	state := checkState(pending, reboot, thresholds)
+	summary := getMessage("pending_summary", pending.Backend, pending.APT, pending.APTSecurity, pending.Snap, pending.Flatpak)
+	if reboot {
+		summary += ", " + getMessage("summary_reboot")
+	}
+	fmt.Printf("%s - %s | %s\n", stateName(state), summary, perfData(pending, reboot, thresholds))
+
+	if writeMetricsFile {
+		path := metricsPath(config)
+		if err := writeMetrics(path, pendingMetrics(pending, countSnapshots())); err != nil {
+			printMessage(Red, getMessage("error_metrics", err))
+			return CheckUnknown
+		}
+	}
+
+	return state
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckState(t *testing.T) {
+	defaults := defaultCheckConfig()
+
+	testCases := []struct {
+		name     string
+		pending  PendingUpdates
+		reboot   bool
+		config   CheckConfig
+		expected int
+	}{
+		{"up to date", PendingUpdates{}, false, defaults, CheckOK},
+		{"pending updates", PendingUpdates{APT: 3}, false, defaults, CheckWarning},
+		{"snap only", PendingUpdates{Snap: 1}, false, defaults, CheckWarning},
+		{"security update", PendingUpdates{APT: 1, APTSecurity: 1}, false, defaults, CheckCritical},
+		{"reboot", PendingUpdates{}, true, defaults, CheckWarning},
+		{"reboot critical", PendingUpdates{}, true, CheckConfig{Reboot: "critical"}, CheckCritical},
+		{"reboot ignored", PendingUpdates{}, true, CheckConfig{Reboot: "ok"}, CheckOK},
+		{"below critical", PendingUpdates{APT: 9}, false, CheckConfig{WarningPending: 5, CriticalPending: 10}, CheckWarning},
+		{"critical count", PendingUpdates{APT: 10}, false, CheckConfig{WarningPending: 5, CriticalPending: 10}, CheckCritical},
+		{"thresholds disabled", PendingUpdates{APT: 50, APTSecurity: 10}, false, CheckConfig{}, CheckOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := checkState(tc.pending, tc.reboot, tc.config); got != tc.expected {
+				t.Errorf("checkState() = %s, attendu %s", stateName(got), stateName(tc.expected))
+			}
+		})
+	}
+}
+
+func TestPerfData(t *testing.T) {
+	out := perfData(PendingUpdates{APT: 4, APTSecurity: 1, Snap: 2}, true, defaultCheckConfig())
+	for _, want := range []string{"pending=6;1;0", "security=1;0;1", "snap=2", "flatpak=0", "reboot=1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("perfData() devrait contenir %q: %s", want, out)
+		}
+	}
+}
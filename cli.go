@@ -0,0 +1,351 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// command is a subcommand of uubu.
+// setup defines the flags of the command on fs, bound to config,
+// and returns the function running the command with the remaining arguments.
+type command struct {
+	name  string
+	setup func(fs *flag.FlagSet, config *Config) func(args []string) int
+	// Hidden commands are left out of the help and the completion
+	hidden bool
+}
+
+// Default command when the first argument is a flag or is missing
+const defaultCommand = "upgrade"
+
+// commands lists the subcommands in the order shown by the help
+var commands []command
+
+// Filled at init: the help command refers to the list itself
+func init() {
+	commands = []command{
+		{"upgrade", setupUpgrade, false},
+		{"resume", setupResume, false},
+		{"release-upgrade", setupReleaseUpgrade, false},
+		{"check", setupCheck, false},
+		{"history", setupHistory, false},
+		{"config", setupConfigCommand, false},
+		{"doctor", setupDoctor, false},
+		{"repos", setupRepos, false},
+		{"completion", setupCompletion, false},
+		{"version", setupVersion, false},
+		{"help", setupHelp, false},
+		{"__complete", setupComplete, true},
+		{"__man", setupMan, true},
+	}
+}
+
+// Short aliases of long flags
+var shortFlags = map[string]string{
+	"h": "help",
+	"v": "version",
+	"s": "snapshot",
+	"q": "quiet",
+}
+
+// Placeholders of the flag values in the help
+var flagArgs = map[string]string{
+	"report":            "FILE",
+	"warning":           "N",
+	"critical":          "N",
+	"security-warning":  "N",
+	"security-critical": "N",
+	"reboot":            "STATE",
+	"limit":             "N",
+	"firmware":          "MODE",
+	"apt-frontend":      "NAME",
+	"changelog":         "MODE",
+	"color":             "WHEN",
+	"wait":              "SECONDS",
+	"key-days":          "N",
+}
+
+// findCommand returns the subcommand called name
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// programName returns the name used in the help
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// newCommandFlags creates the flag set of cmd, including -h/--help and the output flags
+func newCommandFlags(cmd *command, config *Config) (*flag.FlagSet, *bool, func([]string) int) {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	help := new(bool)
+	fs.BoolVar(help, "h", false, getMessage("flag_help"))
+	fs.BoolVar(help, "help", false, getMessage("flag_help"))
+
+	output := addOutputFlags(fs, config)
+	run := cmd.setup(fs, config)
+	return fs, help, func(args []string) int {
+		if err := output.apply(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return ExitUsage
+		}
+		return run(args)
+	}
+}
+
+// runCLI dispatches the command line to a subcommand and returns the exit code.
+// Flag-only invocations run the upgrade command for backward compatibility.
+// configErr is the error of the configuration file, failing the commands reading it.
+func runCLI(config Config, configErr error, args []string) int {
+	cmd := findCommand(defaultCommand)
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd = findCommand(args[0])
+		if cmd == nil {
+			fmt.Fprintln(os.Stderr, getMessage("unknown_command", args[0]))
+			fmt.Fprintln(os.Stderr, getMessage("help_more", programName()))
+			return ExitUsage
+		}
+		args = args[1:]
+	}
+
+	fs, help, run := newCommandFlags(cmd, &config)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			showCommandHelp(cmd)
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, getMessage("help_more", programName()))
+		return ExitUsage
+	}
+	if *help {
+		if cmd.name == defaultCommand {
+			showHelp()
+		} else {
+			showCommandHelp(cmd)
+		}
+		return 0
+	}
+	if configErr != nil && readsConfig(cmd.name, fs) {
+		fmt.Fprintln(os.Stderr, colorize(Red, getMessage("error_config", configErr)))
+		return ExitUsage
+	}
+
+	return run(fs.Args())
+}
+
+// readsConfig tells whether a command uses the configuration file. Help, version,
+// completion and the path of the file work with a broken one; doctor reports it.
+func readsConfig(name string, fs *flag.FlagSet) bool {
+	switch name {
+	case "help", "version", "completion", "doctor", "__complete", "__man":
+		return false
+	}
+	for _, name := range []string{"version", "path", "default"} {
+		if f := fs.Lookup(name); f != nil && f.Value.String() == "true" {
+			return false
+		}
+	}
+	return true
+}
+
+// flagLines formats the flags of fs as aligned help lines
+func flagLines(fs *flag.FlagSet) []string {
+	aliases := make(map[string]string)
+	for short, long := range shortFlags {
+		if fs.Lookup(short) != nil && fs.Lookup(long) != nil {
+			aliases[long] = short
+		}
+	}
+
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if long, ok := shortFlags[f.Name]; ok && fs.Lookup(long) != nil {
+			return
+		}
+		names = append(names, f.Name)
+	})
+	sort.Strings(names)
+
+	left := make([]string, len(names))
+	width := 0
+	for i, name := range names {
+		f := fs.Lookup(name)
+		s := "--" + name
+		if len(name) == 1 {
+			s = "-" + name
+		}
+		if short, ok := aliases[name]; ok {
+			s = "-" + short + ", " + s
+		}
+		if !isBoolFlag(f) {
+			arg, ok := flagArgs[name]
+			if !ok {
+				arg = "VALUE"
+			}
+			s += " " + arg
+		}
+		left[i] = s
+		if len(s) > width {
+			width = len(s)
+		}
+	}
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = fmt.Sprintf("  %-*s  %s", width, left[i], fs.Lookup(name).Usage)
+	}
+	return lines
+}
+
+// isBoolFlag tells whether a flag takes no value
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// commandFlagSet returns the flag set of cmd bound to a throwaway configuration
+func commandFlagSet(cmd *command) *flag.FlagSet {
+	config := defaultConfig()
+	fs, _, _ := newCommandFlags(cmd, &config)
+	return fs
+}
+
+// visibleCommands returns the commands shown in the help and the completion
+func visibleCommands() []command {
+	var visible []command
+	for _, c := range commands {
+		if !c.hidden {
+			visible = append(visible, c)
+		}
+	}
+	return visible
+}
+
+// commandLines formats the list of subcommands
+func commandLines() []string {
+	visible := visibleCommands()
+	width := 0
+	for _, c := range visible {
+		if len(c.name) > width {
+			width = len(c.name)
+		}
+	}
+	lines := make([]string, len(visible))
+	for i, c := range visible {
+		lines[i] = fmt.Sprintf("  %-*s  %s", width, c.name, getMessage("cmd_"+c.name))
+	}
+	return lines
+}
+
+// showHelp displays the general help with the options of the default command
+func showHelp() {
+	name := programName()
+
+	fmt.Printf("%s\n\n%s:\n",
+		getMessage("help_description"),
+		getMessage("description"))
+
+	// Treat long description with indentation
+	descLong := getMessage("help_desc_long")
+	lines := strings.Split(descLong, "\n")
+	for _, line := range lines {
+		fmt.Printf("  %s\n", line)
+	}
+	fmt.Println()
+
+	fmt.Printf("%s\n\n", getMessage("help_usage_commands", name))
+
+	fmt.Printf("%s\n", getMessage("help_commands"))
+	for _, line := range commandLines() {
+		fmt.Println(line)
+	}
+	fmt.Println()
+
+	fmt.Printf("%s\n", getMessage("help_options"))
+	for _, line := range flagLines(commandFlagSet(findCommand(defaultCommand))) {
+		fmt.Println(line)
+	}
+
+	fmt.Printf("\n%s\n", getMessage("help_examples"))
+	examples := [][2]string{
+		{name, getMessage("help_example_1")},
+		{name + " -s", getMessage("help_example_2")},
+		{name + " --no-snap --no-flatpak", getMessage("help_example_4")},
+		{name + " check", getMessage("help_example_check")},
+	}
+	width := 0
+	for _, e := range examples {
+		if len(e[0]) > width {
+			width = len(e[0])
+		}
+	}
+	for _, e := range examples {
+		fmt.Printf("  %-*s  %s\n", width, e[0], e[1])
+	}
+
+	fmt.Printf("\n%s\n", getMessage("help_more", name))
+	fmt.Printf("\n%s\n  NDXDev (NDXDev@gmail.com)\n", getMessage("help_author"))
+	fmt.Printf("\n%s\n  MIT\n", getMessage("help_license"))
+}
+
+// showCommandHelp displays the help of a subcommand
+func showCommandHelp(cmd *command) {
+	fmt.Printf("%s %s - %s\n\n", programName(), cmd.name, getMessage("cmd_"+cmd.name))
+	fmt.Printf("%s%s\n\n", getMessage("help_usage_command", programName(), cmd.name), commandArgs(cmd.name))
+	fmt.Printf("%s\n", getMessage("help_options"))
+	for _, line := range flagLines(commandFlagSet(cmd)) {
+		fmt.Println(line)
+	}
+}
+
+// commandArgs returns the positional arguments of a command for the usage line
+func commandArgs(name string) string {
+	switch name {
+	case "history":
+		return " [RUN_ID]"
+	case "help":
+		return " [COMMAND]"
+	case "completion":
+		return " bash|zsh|fish"
+	}
+	return ""
+}
+
+// setupVersion defines the version command
+func setupVersion(fs *flag.FlagSet, config *Config) func([]string) int {
+	return func([]string) int {
+		showVersion()
+		return 0
+	}
+}
+
+// setupHelp defines the help command
+func setupHelp(fs *flag.FlagSet, config *Config) func([]string) int {
+	return func(args []string) int {
+		if len(args) == 0 {
+			showHelp()
+			return 0
+		}
+		cmd := findCommand(args[0])
+		if cmd == nil {
+			fmt.Fprintln(os.Stderr, getMessage("unknown_command", args[0]))
+			return ExitUsage
+		}
+		showCommandHelp(cmd)
+		return 0
+	}
+}
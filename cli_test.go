@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFindCommand(t *testing.T) {
+	for _, name := range []string{"upgrade", "check", "history", "config", "doctor", "version", "help"} {
+		if findCommand(name) == nil {
+			t.Errorf("La commande %s devrait exister", name)
+		}
+	}
+	if findCommand("install") != nil {
+		t.Error("La commande install ne devrait pas exister")
+	}
+}
+
+func TestRunCLI_Dispatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{"version command", []string{"version"}, 0},
+		{"legacy version flag", []string{"--version"}, 0},
+		{"legacy help flag", []string{"-h"}, 0},
+		{"command help", []string{"check", "--help"}, 0},
+		{"help command", []string{"help", "history"}, 0},
+		{"unknown command", []string{"install"}, 2},
+		{"unknown flag", []string{"--bogus"}, 2},
+		{"help unknown command", []string{"help", "install"}, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runCLI(defaultConfig(), nil, tc.args); got != tc.expected {
+				t.Errorf("runCLI(%v) = %d, attendu %d", tc.args, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRunCLI_ConfigError(t *testing.T) {
+	configErr := errors.New("config.json: invalid character")
+	testCases := []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{"version"}, 0},
+		{[]string{"--version"}, 0},
+		{[]string{"help"}, 0},
+		{[]string{"config", "--path"}, 0},
+		{[]string{"config", "--default"}, 0},
+		{[]string{"completion", "bash"}, 0},
+		{[]string{"config"}, 2},
+		{[]string{"check"}, 2},
+		{[]string{"history"}, 2},
+	}
+
+	for _, tc := range testCases {
+		if got := runCLI(defaultConfig(), configErr, tc.args); got != tc.expected {
+			t.Errorf("runCLI(%v) avec une configuration invalide = %d, attendu %d", tc.args, got, tc.expected)
+		}
+	}
+}
+
+func TestRunCLI_FlagsBoundToConfig(t *testing.T) {
+	config := defaultConfig()
+	cmd := findCommand("check")
+	fs, _, _ := newCommandFlags(cmd, &config)
+	if err := fs.Parse([]string{"--warning", "7", "--reboot", "critical"}); err != nil {
+		t.Fatal(err)
+	}
+	if config.Check.WarningPending != 7 || config.Check.Reboot != "critical" {
+		t.Errorf("Les options devraient modifier la configuration: %+v", config.Check)
+	}
+}
+
+func TestFlagLines(t *testing.T) {
+	lines := flagLines(commandFlagSet(findCommand("upgrade")))
+	text := strings.Join(lines, "\n")
+
+	for _, want := range []string{"-s, --snapshot", "-h, --help", "-v, --version", "--report FILE", "--no-snap"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("L'aide devrait contenir %q:\n%s", want, text)
+		}
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "-s ") {
+			t.Errorf("L'alias court ne devrait pas avoir sa propre ligne: %q", line)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Kinds of values completed by the shells
+const (
+	completeWords    = "words"
+	completeFiles    = "files"
+	completeRuns     = "runs"
+	completeCommands = "commands"
+)
+
+// valueCompletion describes the values accepted by a flag or a positional argument
+type valueCompletion struct {
+	kind  string
+	words []string
+}
+
+// Completion of flag values, by flag name
+var flagCompletions = map[string]valueCompletion{
+	"report":       {kind: completeFiles},
+	"reboot":       {kind: completeWords, words: []string{"ok", "warning", "critical"}},
+	"firmware":     {kind: completeWords, words: []string{"off", "list", "apply"}},
+	"apt-frontend": {kind: completeWords, words: aptFrontendNames()},
+	"changelog":    {kind: completeWords, words: []string{ChangelogOff, ChangelogAll, ChangelogImportant}},
+	"color":        {kind: completeWords, words: []string{ColorAuto, ColorAlways, ColorNever}},
+}
+
+// Completion of positional arguments, by command name
+var argCompletions = map[string]valueCompletion{
+	"history":    {kind: completeRuns},
+	"help":       {kind: completeCommands},
+	"completion": {kind: completeWords, words: []string{"bash", "zsh", "fish"}},
+}
+
+// completionFlag is a flag of a command as seen by the completion
+type completionFlag struct {
+	long   string
+	short  string
+	usage  string
+	isBool bool
+	value  valueCompletion
+}
+
+// completionFlags lists the flags of cmd with their short aliases merged
+func completionFlags(cmd *command) []completionFlag {
+	fs := commandFlagSet(cmd)
+
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		if long, ok := shortFlags[f.Name]; ok && fs.Lookup(long) != nil {
+			return
+		}
+		cf := completionFlag{long: f.Name, usage: f.Usage, isBool: isBoolFlag(f), value: flagCompletions[f.Name]}
+		for short, long := range shortFlags {
+			if long == f.Name && fs.Lookup(short) != nil {
+				cf.short = short
+			}
+		}
+		flags = append(flags, cf)
+	})
+	return flags
+}
+
+// completionCandidates returns the dynamic values of a kind
+func completionCandidates(config Config, kind string) []string {
+	switch kind {
+	case completeRuns:
+		runs, _ := loadHistory(historyPath(config))
+		ids := make([]string, 0, len(runs))
+		for i := len(runs) - 1; i >= 0; i-- {
+			ids = append(ids, runs[i].ID)
+		}
+		return ids
+	case completeCommands:
+		var names []string
+		for _, c := range visibleCommands() {
+			names = append(names, c.name)
+		}
+		return names
+	}
+	return nil
+}
+
+// setupComplete defines the hidden command called by the completion scripts
+func setupComplete(fs *flag.FlagSet, config *Config) func([]string) int {
+	return func(args []string) int {
+		if len(args) != 1 {
+			return ExitUsage
+		}
+		for _, c := range completionCandidates(*config, args[0]) {
+			fmt.Println(c)
+		}
+		return 0
+	}
+}
+
+// setupCompletion defines the completion command
+func setupCompletion(fs *flag.FlagSet, config *Config) func([]string) int {
+	return func(args []string) int {
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, getMessage("completion_usage", programName()))
+			return ExitUsage
+		}
+		switch args[0] {
+		case "bash":
+			fmt.Print(bashCompletion())
+		case "zsh":
+			fmt.Print(zshCompletion())
+		case "fish":
+			fmt.Print(fishCompletion())
+		default:
+			fmt.Fprintln(os.Stderr, getMessage("completion_usage", programName()))
+			return ExitUsage
+		}
+		return 0
+	}
+}
+
+// commandNames returns the names of the visible commands separated by sep
+func commandNames(sep string) string {
+	var names []string
+	for _, c := range visibleCommands() {
+		names = append(names, c.name)
+	}
+	return strings.Join(names, sep)
+}
+
+// bashCompletion generates the bash completion script
+func bashCompletion() string {
+	var sb strings.Builder
+
+	sb.WriteString(`# bash completion for uubu -*- shell-script -*-
+# Generated by "uubu completion bash"
+
+_uubu() {
+    local cur prev cmd i explicit
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+    cmd=upgrade
+    explicit=0
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "${COMP_WORDS[i]}" in
+`)
+	fmt.Fprintf(&sb, "            %s)\n", commandNames("|"))
+	sb.WriteString(`                cmd="${COMP_WORDS[i]}"
+                explicit=1
+                break
+                ;;
+        esac
+    done
+
+    case "$cmd:$prev" in
+`)
+	for _, c := range visibleCommands() {
+		cmd := c
+		for _, f := range completionFlags(&cmd) {
+			if f.isBool || f.value.kind == "" {
+				continue
+			}
+			fmt.Fprintf(&sb, "        %s:--%s|%s:-%s)\n", c.name, f.long, c.name, f.long)
+			fmt.Fprintf(&sb, "            %s\n            return\n            ;;\n", bashValues(f.value))
+		}
+	}
+	sb.WriteString(`    esac
+
+    if [[ "$cur" == -* ]]; then
+        case "$cmd" in
+`)
+	for _, c := range visibleCommands() {
+		cmd := c
+		var words []string
+		for _, f := range completionFlags(&cmd) {
+			if f.short != "" {
+				words = append(words, "-"+f.short)
+			}
+			words = append(words, "--"+f.long)
+		}
+		fmt.Fprintf(&sb, "            %s)\n                COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n                ;;\n",
+			c.name, strings.Join(words, " "))
+	}
+	sb.WriteString(`        esac
+        return
+    fi
+
+    if [[ $explicit == 0 ]]; then
+`)
+	fmt.Fprintf(&sb, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", commandNames(" "))
+	sb.WriteString(`        return
+    fi
+
+    case "$cmd" in
+`)
+	var names []string
+	for name := range argCompletions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&sb, "        %s)\n            %s\n            ;;\n", name, bashValues(argCompletions[name]))
+	}
+	sb.WriteString(`    esac
+} &&
+    complete -F _uubu uubu
+`)
+	return sb.String()
+}
+
+// bashValues returns the bash statement completing a value
+func bashValues(v valueCompletion) string {
+	switch v.kind {
+	case completeFiles:
+		return `COMPREPLY=($(compgen -f -- "$cur"))`
+	case completeWords:
+		return fmt.Sprintf(`COMPREPLY=($(compgen -W "%s" -- "$cur"))`, strings.Join(v.words, " "))
+	}
+	return fmt.Sprintf(`COMPREPLY=($(compgen -W "$(uubu __complete %s 2>/dev/null)" -- "$cur"))`, v.kind)
+}
+
+// zshEscape escapes a description in a single-quoted _arguments spec
+func zshEscape(s string) string {
+	return strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
+
+// zshCompletion generates the zsh completion script
+func zshCompletion() string {
+	var sb strings.Builder
+
+	sb.WriteString(`#compdef uubu
+# zsh completion for uubu
+# Generated by "uubu completion zsh"
+
+_uubu_dynamic() {
+    local -a values
+    values=(${(f)"$(uubu __complete $1 2>/dev/null)"})
+    compadd -a values
+}
+
+_uubu() {
+    local -a commands
+    commands=(
+`)
+	for _, c := range visibleCommands() {
+		fmt.Fprintf(&sb, "        '%s:%s'\n", c.name, zshEscape(getMessage("cmd_"+c.name)))
+	}
+	sb.WriteString(`    )
+
+    local cmd=upgrade
+    if (( CURRENT > 2 )) && [[ -n ${commands[(r)${words[2]}:*]} ]]; then
+        cmd=${words[2]}
+        shift words
+        (( CURRENT-- ))
+    elif (( CURRENT == 2 )) && [[ ${words[2]} != -* ]]; then
+        _describe -t commands 'command' commands
+        return
+    fi
+
+    case $cmd in
+`)
+	for _, c := range visibleCommands() {
+		cmd := c
+		fmt.Fprintf(&sb, "        %s)\n            _arguments -s \\\n", c.name)
+		for _, f := range completionFlags(&cmd) {
+			usage := zshEscape(f.usage)
+			action := ""
+			if !f.isBool {
+				action = ":" + f.long + ":" + zshAction(f.value)
+			}
+			if f.short != "" {
+				fmt.Fprintf(&sb, "                '(-%s --%s)'{-%s,--%s}'[%s]%s' \\\n", f.short, f.long, f.short, f.long, usage, action)
+			} else {
+				fmt.Fprintf(&sb, "                '--%s[%s]%s' \\\n", f.long, usage, action)
+			}
+		}
+		if v, ok := argCompletions[c.name]; ok {
+			fmt.Fprintf(&sb, "                '1:%s:%s'\n", c.name, zshAction(v))
+		} else {
+			sb.WriteString("                && return 0\n")
+		}
+		sb.WriteString("            ;;\n")
+	}
+	sb.WriteString(`    esac
+}
+
+_uubu "$@"
+`)
+	return sb.String()
+}
+
+// zshAction returns the _arguments action completing a value
+func zshAction(v valueCompletion) string {
+	switch v.kind {
+	case completeFiles:
+		return "_files"
+	case completeWords:
+		return "(" + strings.Join(v.words, " ") + ")"
+	case "":
+		return " "
+	}
+	return "{_uubu_dynamic " + v.kind + "}"
+}
+
+// fishEscape escapes a string in single quotes
+func fishEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+// fishCompletion generates the fish completion script
+func fishCompletion() string {
+	var sb strings.Builder
+
+	sb.WriteString(`# fish completion for uubu
+# Generated by "uubu completion fish"
+
+function __fish_uubu_command
+    set -l tokens (commandline -opc)
+    for t in $tokens[2..-1]
+`)
+	fmt.Fprintf(&sb, "        if contains -- $t %s\n", commandNames(" "))
+	sb.WriteString(`            echo $t
+            return
+        end
+    end
+    echo upgrade
+end
+
+function __fish_uubu_using
+    test (__fish_uubu_command) = $argv[1]
+end
+
+complete -c uubu -f
+`)
+	for _, c := range visibleCommands() {
+		fmt.Fprintf(&sb, "complete -c uubu -n __fish_use_subcommand -a %s -d '%s'\n", c.name, fishEscape(getMessage("cmd_"+c.name)))
+	}
+	for _, c := range visibleCommands() {
+		cmd := c
+		cond := fmt.Sprintf("'__fish_uubu_using %s'", c.name)
+		for _, f := range completionFlags(&cmd) {
+			line := fmt.Sprintf("complete -c uubu -n %s", cond)
+			if f.short != "" {
+				line += " -s " + f.short
+			}
+			if len(f.long) == 1 {
+				line += " -o " + f.long
+			} else {
+				line += " -l " + f.long
+			}
+			if !f.isBool {
+				line += " -r" + fishValues(f.value)
+			}
+			line += fmt.Sprintf(" -d '%s'", fishEscape(f.usage))
+			sb.WriteString(line + "\n")
+		}
+		if v, ok := argCompletions[c.name]; ok {
+			fmt.Fprintf(&sb, "complete -c uubu -n %s%s\n", cond, fishValues(v))
+		}
+	}
+	return sb.String()
+}
+
+// fishValues returns the complete options completing a value
+func fishValues(v valueCompletion) string {
+	switch v.kind {
+	case completeFiles:
+		return " -F"
+	case completeWords:
+		return " -a '" + strings.Join(v.words, " ") + "'"
+	case "":
+		return ""
+	}
+	return " -a '(uubu __complete " + v.kind + " 2>/dev/null)'"
+}
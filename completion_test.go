@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBashCompletion_Syntax(t *testing.T) {
+	if !commandExists("bash") {
+		t.Skip("bash non installé")
+	}
+	path := filepath.Join(t.TempDir(), "uubu.bash")
+	if err := os.WriteFile(path, []byte(bashCompletion()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := exec.Command("bash", "-n", path).CombinedOutput(); err != nil {
+		t.Errorf("Script bash invalide: %v\n%s", err, output)
+	}
+}
+
+func TestCompletion_DerivedFromFlags(t *testing.T) {
+	scripts := map[string]string{
+		"bash": bashCompletion(),
+		"zsh":  zshCompletion(),
+		"fish": fishCompletion(),
+	}
+
+	for shell, script := range scripts {
+		for _, want := range []string{"snapshot", "no-flatpak", "security-warning", "__complete", "runs"} {
+			if !strings.Contains(script, want) {
+				t.Errorf("La complétion %s devrait contenir %q", shell, want)
+			}
+		}
+		if strings.Contains(script, "__complete\n") || strings.Contains(script, " __complete)") {
+			t.Errorf("La complétion %s ne devrait pas proposer la commande cachée", shell)
+		}
+	}
+}
+
+func TestCompletionCandidates(t *testing.T) {
+	names := completionCandidates(defaultConfig(), completeCommands)
+	if len(names) == 0 || names[0] != "upgrade" {
+		t.Errorf("Commandes inattendues: %v", names)
+	}
+	for _, n := range names {
+		if n == "__complete" {
+			t.Error("La commande cachée ne devrait pas être proposée")
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	for _, id := range []string{"20250716-100000", "20250717-100000"} {
+		r := sampleResult()
+		r.ID = id
+		if err := appendHistory(path, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := completionCandidates(Config{HistoryFile: path}, completeRuns)
+	if len(ids) != 2 || ids[0] != "20250717-100000" {
+		t.Errorf("Les exécutions les plus récentes devraient venir en premier: %v", ids)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Default location of the configuration file
+const defaultConfigPath = "/etc/uubu/config.json"
+
+type Config struct {
+	CreateSnapshot    bool `json:"snapshot"`
+	UpdateSnap        bool `json:"snap"`
+	UpdateFlatpak     bool `json:"flatpak"`
+	CheckRebootNeeded bool `json:"reboot_check"`
+	DistUpgrade       bool `json:"dist_upgrade"`
+	// Frontend driving APT: "apt", "apt-get", "nala" or "aptitude"
+	AptFrontend string `json:"apt_frontend"`
+	// Firmware step: "off", "list" or "apply"
+	Firmware string `json:"firmware"`
+	// Changelog preview before upgrading: "off", "all" or "important"
+	Changelog string `json:"changelog"`
+	// Days the updates deselected with --hold-deselected stay held back
+	HoldDays int `json:"hold_days"`
+	// Interactive selection of the updates (--select, --hold-deselected), not saved in the journal
+	Select         bool `json:"-"`
+	HoldDeselected bool `json:"-"`
+	// Live dashboard of the upgrade command (--tui), not saved in the journal
+	TUI bool `json:"-"`
+	// Colors of the output: "auto", "always" or "never"
+	Color string `json:"color"`
+	// Check of the APT repositories after the system step
+	RepoCheck bool `json:"repo_check"`
+	// Days before the expiry of a signing key to warn about it
+	KeyExpiryDays int `json:"key_expiry_days"`
+
+	// Log file of the runs (empty: default path, "off": no log file), rotated
+	// above log_max_size MiB keeping log_keep old files
+	LogFile    string `json:"log_file"`
+	LogMaxSize int    `json:"log_max_size"`
+	LogKeep    int    `json:"log_keep"`
+	// Log to journald when running under systemd
+	Journald bool `json:"journald"`
+
+	// Path of the JSON run report (empty: no report)
+	ReportFile string `json:"report_file"`
+	// Prometheus textfile written after each run (empty: no metrics)
+	MetricsFile string `json:"metrics_file"`
+	// Run history (default: ~/.local/state/uubu/history.jsonl)
+	HistoryFile string `json:"history_file"`
+	// Directory of the external updaters (empty: no plugins)
+	PluginDir string `json:"plugin_dir"`
+	// Directory of the <phase>.d hook directories (empty: no hooks)
+	HooksDir string `json:"hooks_dir"`
+	// Behavior when a hook fails: "abort" or "warn"
+	HookFailure string `json:"hook_failure"`
+	// Lock preventing concurrent runs, and seconds to wait for another run (0: fail at once)
+	LockFile string `json:"lock_file"`
+	LockWait int    `json:"lock_wait"`
+	// Seconds allowed to each step ("apt", "snap", "firmware"...), missing or 0: no limit
+	StepTimeouts map[string]int `json:"step_timeouts"`
+	// Retries of the commands failing for a transient reason (network, locks)
+	Retry RetryConfig `json:"retry"`
+
+	// Thresholds of the check subcommand
+	Check CheckConfig `json:"check"`
+
+	Notifications []NotifierConfig `json:"notifications"`
+	Email         *EmailConfig     `json:"email"`
+}
+
+// defaultConfig returns the configuration used when no file overrides it
+func defaultConfig() Config {
+	return Config{
+		CreateSnapshot:    false,
+		UpdateSnap:        true,
+		UpdateFlatpak:     true,
+		CheckRebootNeeded: true,
+		AptFrontend:       defaultAptFrontend,
+		Firmware:          FirmwareOff,
+		Changelog:         ChangelogOff,
+		HoldDays:          defaultHoldDays,
+		Color:             ColorAuto,
+		RepoCheck:         true,
+		KeyExpiryDays:     defaultKeyExpiryDays,
+		PluginDir:         defaultPluginDir,
+		HooksDir:          defaultHooksDir,
+		HookFailure:       HookWarn,
+		LockFile:          defaultLockFile,
+		LogMaxSize:        defaultLogMaxSize,
+		LogKeep:           defaultLogKeep,
+		Journald:          true,
+		Retry:             defaultRetryConfig(),
+		Check:             defaultCheckConfig(),
+	}
+}
+
+// configPath returns the configuration file to read
+func configPath() string {
+	// Priority: UUBU_CONFIG environment variable
+	if path := os.Getenv("UUBU_CONFIG"); path != "" {
+		return path
+	}
+	return defaultConfigPath
+}
+
+// loadConfig reads the configuration file on top of the defaults.
+// A missing file is not an error: the defaults are returned.
+func loadConfig(path string) (Config, error) {
+	config := defaultConfig()
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path chosen by the administrator
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, err
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if !validFirmwareMode(config.Firmware) {
+		return config, fmt.Errorf("%s: firmware: unknown mode %q", path, config.Firmware)
+	}
+
+	if !validAptFrontend(config.AptFrontend) {
+		return config, fmt.Errorf("%s: apt_frontend: unknown frontend %q", path, config.AptFrontend)
+	}
+
+	if !validChangelogMode(config.Changelog) {
+		return config, fmt.Errorf("%s: changelog: unknown mode %q", path, config.Changelog)
+	}
+
+	if config.LogMaxSize < 1 {
+		return config, fmt.Errorf("%s: log_max_size: must be at least 1", path)
+	}
+
+	if config.LogKeep < 0 {
+		return config, fmt.Errorf("%s: log_keep: must not be negative", path)
+	}
+
+	if !validColorMode(config.Color) {
+		return config, fmt.Errorf("%s: color: unknown mode %q", path, config.Color)
+	}
+
+	if config.HoldDays < 1 {
+		return config, fmt.Errorf("%s: hold_days: must be at least 1", path)
+	}
+
+	if config.KeyExpiryDays < 0 {
+		return config, fmt.Errorf("%s: key_expiry_days: must not be negative", path)
+	}
+
+	if err := config.Retry.validate(); err != nil {
+		return config, fmt.Errorf("%s: retry: %v", path, err)
+	}
+
+	switch config.HookFailure {
+	case HookAbort, HookWarn:
+	default:
+		return config, fmt.Errorf("%s: hook_failure: unknown behavior %q", path, config.HookFailure)
+	}
+
+	switch config.Check.Reboot {
+	case "ok", "warning", "critical":
+	default:
+		return config, fmt.Errorf("%s: check.reboot: unknown state %q", path, config.Check.Reboot)
+	}
+
+	for i, n := range config.Notifications {
+		if _, err := newNotifier(n); err != nil {
+			return config, fmt.Errorf("%s: notifications[%d]: %v", path, i, err)
+		}
+	}
+
+	if config.Email != nil {
+		if err := config.Email.validate(); err != nil {
+			return config, fmt.Errorf("%s: email: %v", path, err)
+		}
+	}
+
+	return config, nil
+}
+
+// Replacement of secrets in the displayed configuration
+const maskedSecret = "********"
+
+// masked returns a copy of config without passwords nor tokens
+func (config Config) masked() Config {
+	notifications := make([]NotifierConfig, len(config.Notifications))
+	for i, n := range config.Notifications {
+		n.URL = maskedURL(n.URL)
+		if n.Token != "" {
+			n.Token = maskedSecret
+		}
+		if len(n.Headers) > 0 {
+			headers := make(map[string]string, len(n.Headers))
+			for k := range n.Headers {
+				headers[k] = maskedSecret
+			}
+			n.Headers = headers
+		}
+		notifications[i] = n
+	}
+	config.Notifications = notifications
+
+	if config.Email != nil {
+		email := *config.Email
+		if email.Password != "" {
+			email.Password = maskedSecret
+		}
+		config.Email = &email
+	}
+	return config
+}
+
+// maskedURL keeps the host of a URL only: the path of Slack and Discord webhooks
+// and of ntfy topics is a secret, like credentials and query parameters
+func maskedURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		if raw == "" {
+			return raw
+		}
+		return maskedSecret
+	}
+	if u.User == nil && strings.Trim(u.Path, "/") == "" && u.RawQuery == "" {
+		return raw
+	}
+	return u.Scheme + "://" + u.Host + "/" + maskedSecret
+}
+
+// setupConfigCommand defines the config command
+func setupConfigCommand(fs *flag.FlagSet, config *Config) func([]string) int {
+	showPath := fs.Bool("path", false, getMessage("flag_config_path"))
+	showDefault := fs.Bool("default", false, getMessage("flag_config_default"))
+
+	return func([]string) int {
+		path := configPath()
+		if *showPath {
+			fmt.Println(path)
+			return 0
+		}
+		if *showDefault {
+			return printJSON(defaultConfig())
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			printMessage(Yellow, getMessage("config_missing", path))
+		} else {
+			printMessage(Blue, getMessage("config_file", path))
+		}
+		return printJSON(config.masked())
+	}
+}
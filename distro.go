@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Distro is the distribution described by os-release(5)
+type Distro struct {
+	ID         string   `json:"id"`
+	IDLike     []string `json:"id_like,omitempty"`
+	Name       string   `json:"name"`
+	VersionID  string   `json:"version_id,omitempty"`
+	Codename   string   `json:"codename,omitempty"`
+	PrettyName string   `json:"pretty_name"`
+	// Ubuntu or Debian release a derivative is based on
+	UbuntuCodename string `json:"ubuntu_codename,omitempty"`
+	DebianCodename string `json:"debian_codename,omitempty"`
+}
+
+// os-release files in lookup order
+var osReleaseFiles = []string{"/etc/os-release", "/usr/lib/os-release"}
+
+// Distributions uubu is tested on; kubuntu, xubuntu... report ID=ubuntu
+var supportedDistros = []string{"ubuntu", "debian", "linuxmint", "pop", "elementary", "fedora", "arch"}
+
+// End of the standard security support, by codename
+var (
+	ubuntuEndOfLife = map[string]string{
+		"xenial":   "2021-04-30",
+		"bionic":   "2023-05-31",
+		"focal":    "2025-05-29",
+		"jammy":    "2027-06-01",
+		"lunar":    "2024-01-25",
+		"mantic":   "2024-07-11",
+		"noble":    "2029-05-31",
+		"oracular": "2025-07-10",
+		"plucky":   "2026-01-15",
+	}
+	debianEndOfLife = map[string]string{
+		"stretch":  "2020-07-06",
+		"buster":   "2022-09-10",
+		"bullseye": "2024-08-14",
+		"bookworm": "2026-06-10",
+	}
+)
+
+// parseOSRelease reads the KEY=value lines of an os-release file
+func parseOSRelease(content string) Distro {
+	values := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `"'`)
+		}
+		values[key] = value
+	}
+
+	d := Distro{
+		ID:             strings.ToLower(values["ID"]),
+		IDLike:         strings.Fields(strings.ToLower(values["ID_LIKE"])),
+		Name:           values["NAME"],
+		VersionID:      values["VERSION_ID"],
+		Codename:       values["VERSION_CODENAME"],
+		PrettyName:     values["PRETTY_NAME"],
+		UbuntuCodename: values["UBUNTU_CODENAME"],
+		DebianCodename: values["DEBIAN_CODENAME"],
+	}
+	if d.PrettyName == "" {
+		d.PrettyName = strings.TrimSpace(d.Name + " " + values["VERSION"])
+	}
+	return d
+}
+
+// detectDistro reads the os-release file of the system.
+// The ID is empty when no file could be read.
+func detectDistro() Distro {
+	for _, path := range osReleaseFiles {
+		data, err := os.ReadFile(path) // #nosec G304 -- fixed system paths
+		if err == nil {
+			return parseOSRelease(string(data))
+		}
+	}
+	return Distro{PrettyName: "unknown"}
+}
+
+// is tells whether the distribution is one of ids or derives from one of them
+func (d Distro) is(ids ...string) bool {
+	for _, id := range ids {
+		if d.ID == id {
+			return true
+		}
+		for _, like := range d.IDLike {
+			if like == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// supported tells whether uubu is tested on the distribution
+func (d Distro) supported() bool {
+	for _, id := range supportedDistros {
+		if d.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// endOfLife returns the end of the security support of the release, or of the
+// Ubuntu or Debian release it is based on; ok is false when it is not known
+func (d Distro) endOfLife() (eol time.Time, ok bool) {
+	var date string
+	switch {
+	case d.UbuntuCodename != "":
+		date, ok = ubuntuEndOfLife[d.UbuntuCodename]
+	case d.ID == "ubuntu":
+		date, ok = ubuntuEndOfLife[d.Codename]
+	case d.DebianCodename != "":
+		date, ok = debianEndOfLife[d.DebianCodename]
+	case d.ID == "debian":
+		date, ok = debianEndOfLife[d.Codename]
+	}
+	if !ok {
+		return time.Time{}, false
+	}
+	eol, err := time.Parse("2006-01-02", date)
+	return eol, err == nil
+}
+
+// shipsSnap tells whether Snap is part of the default installation.
+// Elsewhere a missing snap is expected and not worth a warning.
+func (d Distro) shipsSnap() bool {
+	return d.ID == "ubuntu"
+}
+
+// upgradeCommand returns the tool of the distribution installing the package
+// updates, nil to use apt
+func (d Distro) upgradeCommand() []string {
+	switch {
+	case d.ID == "linuxmint" && commandExists("mintupdate-cli"):
+		// Honors the update policy of the Update Manager (ignored packages)
+		return []string{"sudo", "mintupdate-cli", "-y", "upgrade"}
+	case d.ID == "pop" && commandExists("pop-upgrade"):
+		// Also keeps the Pop!_OS specific packages and repositories in step
+		return []string{"sudo", "pop-upgrade", "release", "update"}
+	}
+	return nil
+}
+
+// String describes the distribution for the version output and the reports
+func (d Distro) String() string {
+	if d.Codename != "" && !strings.Contains(d.PrettyName, d.Codename) {
+		return fmt.Sprintf("%s (%s)", d.PrettyName, d.Codename)
+	}
+	return d.PrettyName
+}
+
+// platformWarning returns the warning about the distribution, empty when it is
+// supported and maintained at now
+func platformWarning(d Distro, now time.Time) string {
+	if eol, ok := d.endOfLife(); ok && now.After(eol) {
+		return getMessage("platform_eol", d.PrettyName, eol.Format("2006-01-02"))
+	}
+	if d.ID == "" {
+		return getMessage("platform_unknown")
+	}
+	if !d.supported() && d.is("ubuntu", "debian", "fedora", "rhel", "arch") {
+		return getMessage("platform_untested", d.PrettyName)
+	}
+	if !d.supported() {
+		return getMessage("platform_unsupported", d.PrettyName)
+	}
+	return ""
+}
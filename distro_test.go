@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	ubuntuOSRelease = `PRETTY_NAME="Ubuntu 24.04.1 LTS"
+NAME="Ubuntu"
+VERSION_ID="24.04"
+VERSION="24.04.1 LTS (Noble Numbat)"
+VERSION_CODENAME=noble
+ID=ubuntu
+ID_LIKE=debian
+UBUNTU_CODENAME=noble
+`
+	mintOSRelease = `NAME="Linux Mint"
+VERSION="21.3 (Virginia)"
+ID=linuxmint
+ID_LIKE="ubuntu debian"
+PRETTY_NAME="Linux Mint 21.3"
+VERSION_ID="21.3"
+VERSION_CODENAME=virginia
+UBUNTU_CODENAME=jammy
+`
+	debianOSRelease = `PRETTY_NAME="Debian GNU/Linux 11 (bullseye)"
+NAME="Debian GNU/Linux"
+VERSION_ID="11"
+VERSION_CODENAME=bullseye
+ID=debian
+`
+	zorinOSRelease = `# Zorin OS
+NAME="Zorin OS"
+VERSION="17.1"
+ID=zorin
+ID_LIKE="ubuntu debian"
+PRETTY_NAME='Zorin OS 17.1'
+UBUNTU_CODENAME=jammy
+`
+	fedoraOSRelease = `NAME="Fedora Linux"
+VERSION="40 (Workstation Edition)"
+ID=fedora
+VERSION_ID=40
+PRETTY_NAME="Fedora Linux 40 (Workstation Edition)"
+`
+)
+
+func TestParseOSRelease(t *testing.T) {
+	d := parseOSRelease(mintOSRelease)
+	if d.ID != "linuxmint" || d.VersionID != "21.3" || d.Codename != "virginia" || d.UbuntuCodename != "jammy" {
+		t.Errorf("Champs inattendus: %+v", d)
+	}
+	if d.PrettyName != "Linux Mint 21.3" {
+		t.Errorf("PrettyName = %q, attendu %q", d.PrettyName, "Linux Mint 21.3")
+	}
+	if !d.is("ubuntu") || !d.is("debian") || d.is("fedora") {
+		t.Errorf("ID_LIKE mal interprété: %v", d.IDLike)
+	}
+
+	if d := parseOSRelease(zorinOSRelease); d.PrettyName != "Zorin OS 17.1" {
+		t.Errorf("Guillemets simples: PrettyName = %q", d.PrettyName)
+	}
+	if d := parseOSRelease("NAME=Foo\nVERSION=2\n"); d.PrettyName != "Foo 2" {
+		t.Errorf("PrettyName par défaut = %q, attendu %q", d.PrettyName, "Foo 2")
+	}
+}
+
+func TestDistroEndOfLife(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{ubuntuOSRelease, "2029-05-31"},
+		{mintOSRelease, "2027-06-01"},
+		{debianOSRelease, "2024-08-14"},
+		{fedoraOSRelease, ""},
+	}
+
+	for _, tt := range tests {
+		d := parseOSRelease(tt.content)
+		eol, ok := d.endOfLife()
+		got := ""
+		if ok {
+			got = eol.Format("2006-01-02")
+		}
+		if got != tt.expected {
+			t.Errorf("endOfLife(%s) = %q, attendu %q", d.ID, got, tt.expected)
+		}
+	}
+}
+
+func TestPlatformWarning(t *testing.T) {
+	if err := loadLanguage("en"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		distro   Distro
+		contains string
+	}{
+		{"ubuntu maintenu", parseOSRelease(ubuntuOSRelease), ""},
+		{"debian en fin de vie", parseOSRelease(debianOSRelease), "2024-08-14"},
+		{"dérivée non testée", parseOSRelease(zorinOSRelease), "not tested"},
+		{"fedora", parseOSRelease(fedoraOSRelease), ""},
+		{"non prise en charge", parseOSRelease("ID=alpine\nPRETTY_NAME=\"Alpine Linux v3.20\"\n"), "not supported"},
+		{"non détectée", Distro{}, "os-release"},
+	}
+
+	for _, tt := range tests {
+		got := platformWarning(tt.distro, now)
+		if tt.contains == "" && got != "" {
+			t.Errorf("%s: avertissement inattendu %q", tt.name, got)
+		}
+		if tt.contains != "" && !strings.Contains(got, tt.contains) {
+			t.Errorf("%s: %q devrait contenir %q", tt.name, got, tt.contains)
+		}
+	}
+}
+
+func TestDistroString(t *testing.T) {
+	if got := parseOSRelease(mintOSRelease).String(); got != "Linux Mint 21.3 (virginia)" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := parseOSRelease(debianOSRelease).String(); got != "Debian GNU/Linux 11 (bullseye)" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// doctorResult is the outcome of one diagnostic
+type doctorResult struct {
+	status string // StatusOK, StatusWarning or StatusFailed
+	detail string
+}
+
+// doctorCheck is one diagnostic of the doctor command
+type doctorCheck struct {
+	key string // locale key of the description
+	run func(config Config) doctorResult
+}
+
+// doctorChecks lists the diagnostics in display order
+var doctorChecks = []doctorCheck{
+	{"doctor_config", doctorConfig},
+	{"doctor_user", doctorUser},
+	{"doctor_platform", doctorPlatform},
+	{"doctor_package_manager", doctorPackageManager},
+	{"doctor_sudo", doctorSudo},
+	{"doctor_internet", doctorInternet},
+	{"doctor_timeshift", doctorOptional("timeshift", func(c Config) bool { return c.CreateSnapshot })},
+	{"doctor_snap", doctorOptional("snap", func(c Config) bool { return c.UpdateSnap })},
+	{"doctor_flatpak", doctorOptional("flatpak", func(c Config) bool { return c.UpdateFlatpak })},
+	{"doctor_fwupd", doctorOptional("fwupdmgr", func(c Config) bool { return c.Firmware != FirmwareOff })},
+	{"doctor_plugins", doctorPlugins},
+	{"doctor_lock", doctorLock},
+	{"doctor_history", doctorHistory},
+	{"doctor_reboot", doctorReboot},
+}
+
+func doctorConfig(config Config) doctorResult {
+	path := configPath()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return doctorResult{StatusOK, getMessage("config_missing", path)}
+	}
+	if _, err := loadConfig(path); err != nil {
+		return doctorResult{StatusFailed, err.Error()}
+	}
+	return doctorResult{StatusOK, path}
+}
+
+func doctorUser(config Config) doctorResult {
+	u, err := user.Current()
+	if err != nil {
+		return doctorResult{StatusFailed, err.Error()}
+	}
+	if u.Uid == "0" {
+		return doctorResult{StatusFailed, getMessage("no_root")}
+	}
+	return doctorResult{StatusOK, u.Username}
+}
+
+func doctorPlatform(config Config) doctorResult {
+	distro := detectDistro()
+	if warning := platformWarning(distro, time.Now()); warning != "" {
+		return doctorResult{StatusWarning, warning}
+	}
+	return doctorResult{StatusOK, distro.String()}
+}
+
+// doctorCommand checks that a mandatory command is installed
+func doctorCommand(name string) func(Config) doctorResult {
+	return func(Config) doctorResult {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return doctorResult{StatusFailed, getMessage("doctor_not_found", name)}
+		}
+		return doctorResult{StatusOK, path}
+	}
+}
+
+// doctorPackageManager checks the package manager of the distribution
+func doctorPackageManager(config Config) doctorResult {
+	return doctorCommand(systemBackend(detectDistro(), config.AptFrontend).command())(config)
+}
+
+// doctorOptional checks a command only needed when enabled in the configuration
+func doctorOptional(name string, enabled func(Config) bool) func(Config) doctorResult {
+	return func(config Config) doctorResult {
+		path, err := exec.LookPath(name)
+		switch {
+		case err == nil:
+			return doctorResult{StatusOK, path}
+		case enabled(config):
+			return doctorResult{StatusWarning, getMessage("doctor_not_found", name)}
+		}
+		return doctorResult{StatusOK, getMessage("doctor_disabled")}
+	}
+}
+
+func doctorSudo(config Config) doctorResult {
+	if !commandExists("sudo") {
+		return doctorResult{StatusFailed, getMessage("doctor_not_found", "sudo")}
+	}
+	// -n: fails instead of prompting when a password is needed
+	if _, err := runCommand("sudo", "-n", "true"); err != nil {
+		return doctorResult{StatusWarning, getMessage("doctor_sudo_password")}
+	}
+	return doctorResult{StatusOK, getMessage("doctor_sudo_ok")}
+}
+
+func doctorInternet(config Config) doctorResult {
+	conn, err := net.DialTimeout("tcp", "google.com:80", 3*time.Second)
+	if err != nil {
+		return doctorResult{StatusFailed, err.Error()}
+	}
+	conn.Close()
+	return doctorResult{StatusOK, getMessage("internet_ok")}
+}
+
+func doctorPlugins(config Config) doctorResult {
+	plugins := discoverPlugins(config.PluginDir)
+	if len(plugins) == 0 {
+		return doctorResult{StatusOK, getMessage("doctor_no_plugins")}
+	}
+	var names []string
+	for _, p := range plugins {
+		if !p.Detect() {
+			return doctorResult{StatusWarning, getMessage("doctor_plugin_missing", p.Name())}
+		}
+		names = append(names, p.Name())
+	}
+	return doctorResult{StatusOK, strings.Join(names, ", ")}
+}
+
+func doctorLock(config Config) doctorResult {
+	holder, held := lockHolder(config.LockFile)
+	if !held {
+		return doctorResult{StatusOK, getMessage("doctor_lock_free")}
+	}
+	return doctorResult{StatusWarning, (&errLocked{holder: holder}).Error()}
+}
+
+func doctorHistory(config Config) doctorResult {
+	path := historyPath(config)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return doctorResult{StatusWarning, err.Error()}
+	}
+	f, err := os.CreateTemp(dir, ".uubu-doctor-*")
+	if err != nil {
+		return doctorResult{StatusWarning, err.Error()}
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return doctorResult{StatusOK, path}
+}
+
+func doctorReboot(config Config) doctorResult {
+	if rebootRequired() {
+		return doctorResult{StatusWarning, getMessage("summary_reboot")}
+	}
+	return doctorResult{StatusOK, getMessage("no_reboot")}
+}
+
+// setupDoctor defines the doctor command
+func setupDoctor(fs *flag.FlagSet, config *Config) func([]string) int {
+	return func([]string) int {
+		failed := false
+		for _, c := range doctorChecks {
+			res := c.run(*config)
+			color, mark := Green, "✓"
+			switch res.status {
+			case StatusWarning:
+				color, mark = Yellow, "!"
+			case StatusFailed:
+				color, mark = Red, "✗"
+				failed = true
+			}
+			printMessage(color, fmt.Sprintf("%s %-28s %s", mark, getMessage(c.key), res.detail))
+		}
+
+		if failed {
+			return ExitError
+		}
+		return 0
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestDoctorCommand(t *testing.T) {
+	if res := doctorCommand("ls")(defaultConfig()); res.status != StatusOK {
+		t.Errorf("ls devrait être trouvé: %+v", res)
+	}
+	if res := doctorCommand("commandthatdoesnotexist123")(defaultConfig()); res.status != StatusFailed {
+		t.Errorf("Une commande obligatoire absente devrait échouer: %+v", res)
+	}
+}
+
+func TestDoctorOptional(t *testing.T) {
+	enabled := func(c Config) bool { return c.CreateSnapshot }
+	check := doctorOptional("commandthatdoesnotexist123", enabled)
+
+	config := defaultConfig()
+	if res := check(config); res.status != StatusOK {
+		t.Errorf("Une commande absente mais désactivée ne devrait pas alerter: %+v", res)
+	}
+	config.CreateSnapshot = true
+	if res := check(config); res.status != StatusWarning {
+		t.Errorf("Une commande absente mais activée devrait alerter: %+v", res)
+	}
+}
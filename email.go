@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os/exec"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// EmailConfig describes the email report in the configuration file
+type EmailConfig struct {
+	From   string   `json:"from"`
+	To     []string `json:"to"`
+	Events []string `json:"events"`
+	// smtp or sendmail (default: sendmail)
+	Method string `json:"method"`
+
+	SMTPHost string `json:"smtp_host"`
+	SMTPPort int    `json:"smtp_port"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Refuse to send if the server does not offer STARTTLS
+	StartTLS bool `json:"starttls"`
+
+	SendmailPath string `json:"sendmail_path,omitempty"`
+}
+
+// validate checks the email configuration
+func (c *EmailConfig) validate() error {
+	if len(c.To) == 0 {
+		return fmt.Errorf("missing recipients")
+	}
+	for _, e := range c.Events {
+		if e != EventAlways && e != EventFailure && e != EventReboot {
+			return fmt.Errorf("unknown event %q", e)
+		}
+	}
+	switch c.Method {
+	case "", "sendmail":
+	case "smtp":
+		if c.SMTPHost == "" {
+			return fmt.Errorf("smtp requires smtp_host")
+		}
+	default:
+		return fmt.Errorf("unknown method %q", c.Method)
+	}
+	return nil
+}
+
+// Templates of the email body, messages come from the locale files
+const emailTextTemplate = `{{msg "email_intro" .Hostname}}
+
+{{msg "email_status"}}: {{if .Success}}{{msg "summary_success"}}{{else}}{{msg "summary_failure"}}{{end}}
+{{msg "summary_platform" .Platform}}
+{{- if .PlatformWarning}}
+{{.PlatformWarning}}{{end}}
+{{msg "start_time" (date .StartTime)}}
+{{msg "end_time" (date .EndTime)}}
+
+{{msg "email_upgraded" (len .Upgraded)}}
+{{range .Upgraded}}  - {{.}}
+{{end}}
+{{- if .Firmware}}
+{{msg "firmware_available" (len .Firmware)}}
+{{range .Firmware}}  - {{.Device}}: {{.Current}} → {{.Version}}
+{{end}}{{end}}
+{{- if .Errors}}
+{{msg "summary_errors" (len .Errors)}}
+{{range .Errors}}  - {{.}}
+{{end}}{{end}}
+{{if .SnapshotCreated}}{{msg "summary_snapshot"}}
+{{end}}{{if .RebootRequired}}{{msg "summary_reboot"}}{{else}}{{msg "no_reboot"}}{{end}}
+`
+
+const emailHTMLTemplate = `<!DOCTYPE html>
+<html><body style="font-family: sans-serif">
+<h2>{{msg "email_intro" .Hostname}}</h2>
+<p><strong>{{msg "email_status"}}:</strong>
+{{if .Success}}<span style="color: green">{{msg "summary_success"}}</span>{{else}}<span style="color: red">{{msg "summary_failure"}}</span>{{end}}</p>
+<p>{{msg "summary_platform" .Platform}}
+{{if .PlatformWarning}}<br><strong style="color: orange">{{.PlatformWarning}}</strong>{{end}}</p>
+<p>{{msg "start_time" (date .StartTime)}}<br>{{msg "end_time" (date .EndTime)}}</p>
+<h3>{{msg "email_upgraded" (len .Upgraded)}}</h3>
+{{if .Upgraded}}<ul>{{range .Upgraded}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .Firmware}}<h3>{{msg "firmware_available" (len .Firmware)}}</h3>
+<ul>{{range .Firmware}}<li>{{.Device}}: {{.Current}} → {{.Version}}</li>{{end}}</ul>{{end}}
+{{if .Errors}}<h3 style="color: red">{{msg "summary_errors" (len .Errors)}}</h3>
+<ul>{{range .Errors}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .SnapshotCreated}}<p>{{msg "summary_snapshot"}}</p>{{end}}
+<p>{{if .RebootRequired}}<strong style="color: orange">{{msg "summary_reboot"}}</strong>{{else}}{{msg "no_reboot"}}{{end}}</p>
+</body></html>
+`
+
+// Functions available to the email templates
+var emailFuncs = map[string]interface{}{
+	"msg": getMessage,
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+}
+
+// renderEmail renders the text and HTML bodies of the report
+func renderEmail(r *RunResult) (string, string, error) {
+	textTmpl, err := texttemplate.New("text").Funcs(emailFuncs).Parse(emailTextTemplate)
+	if err != nil {
+		return "", "", err
+	}
+	htmlTmpl, err := htmltemplate.New("html").Funcs(emailFuncs).Parse(emailHTMLTemplate)
+	if err != nil {
+		return "", "", err
+	}
+
+	var text, html bytes.Buffer
+	if err := textTmpl.Execute(&text, r); err != nil {
+		return "", "", err
+	}
+	if err := htmlTmpl.Execute(&html, r); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+// buildEmail assembles the multipart/alternative message
+func buildEmail(c *EmailConfig, r *RunResult) ([]byte, error) {
+	text, html, err := renderEmail(r)
+	if err != nil {
+		return nil, err
+	}
+
+	status := getMessage("summary_success")
+	if !r.Success {
+		status = getMessage("summary_failure")
+	}
+	subject := getMessage("summary_title", r.Hostname, status)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.sender(r))
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+// sender returns the From address, defaulting to uubu@hostname
+func (c *EmailConfig) sender(r *RunResult) string {
+	if c.From != "" {
+		return c.From
+	}
+	return "uubu@" + r.Hostname
+}
+
+// sendEmail sends the run report by email
+func sendEmail(c *EmailConfig, r *RunResult) error {
+	msg, err := buildEmail(c, r)
+	if err != nil {
+		return err
+	}
+	if c.Method == "smtp" {
+		return sendSMTP(c, c.sender(r), msg)
+	}
+	return sendSendmail(c, msg)
+}
+
+// sendSendmail pipes the message to the local sendmail
+func sendSendmail(c *EmailConfig, msg []byte) error {
+	path := c.SendmailPath
+	if path == "" {
+		path = "/usr/sbin/sendmail"
+	}
+	cmd := exec.Command(path, "-t", "-i") // #nosec G204 -- path from the administrator configuration
+	cmd.Stdin = bytes.NewReader(msg)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v: %s", path, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// smtpTimeout bounds the whole SMTP exchange: a stalled server must not hang the run
+var smtpTimeout = 30 * time.Second
+
+// sendSMTP delivers the message through an SMTP server
+func sendSMTP(c *EmailConfig, from string, msg []byte) error {
+	port := c.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(c.SMTPHost, strconv.Itoa(port))
+
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	client, err := smtp.NewClient(conn, c.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: c.SMTPHost, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	} else if c.StartTLS {
+		return fmt.Errorf("%s does not support STARTTLS", addr)
+	}
+
+	if c.Username != "" {
+		// PlainAuth refuses to send credentials over an unencrypted connection
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.SMTPHost)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, to := range c.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
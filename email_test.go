@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailConfigValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		config      EmailConfig
+		expectError bool
+	}{
+		{"sendmail default", EmailConfig{To: []string{"root@localhost"}}, false},
+		{"smtp", EmailConfig{To: []string{"a@b"}, Method: "smtp", SMTPHost: "mail"}, false},
+		{"no recipient", EmailConfig{}, true},
+		{"smtp without host", EmailConfig{To: []string{"a@b"}, Method: "smtp"}, true},
+		{"unknown method", EmailConfig{To: []string{"a@b"}, Method: "pigeon"}, true},
+		{"unknown event", EmailConfig{To: []string{"a@b"}, Events: []string{"never"}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.validate()
+			if (err != nil) != tc.expectError {
+				t.Errorf("validate() = %v, erreur attendue: %v", err, tc.expectError)
+			}
+		})
+	}
+}
+
+func TestRenderEmail(t *testing.T) {
+	if err := loadLanguage("en"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := sampleResult()
+	r.Upgraded = []string{"firefox", "<script>"}
+	r.addStep("snap", time.Now(), errors.New("store timeout"), false)
+	r.RebootRequired = true
+
+	text, html, err := renderEmail(r)
+	if err != nil {
+		t.Fatalf("renderEmail() a retourné une erreur: %v", err)
+	}
+
+	for _, want := range []string{"host1", "firefox", "snap: store timeout", getMessage("summary_reboot")} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Le texte devrait contenir %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "[MISSING") || strings.Contains(html, "[MISSING") {
+		t.Error("Les modèles utilisent une clé de traduction absente")
+	}
+	if strings.Contains(html, "<script>") || !strings.Contains(html, "&lt;script&gt;") {
+		t.Error("Le HTML devrait échapper les noms de paquets")
+	}
+}
+
+func TestBuildEmail(t *testing.T) {
+	c := &EmailConfig{To: []string{"admin@example.org", "ops@example.org"}}
+	msg, err := buildEmail(c, sampleResult())
+	if err != nil {
+		t.Fatalf("buildEmail() a retourné une erreur: %v", err)
+	}
+
+	s := string(msg)
+	for _, want := range []string{
+		"From: uubu@host1\r\n",
+		"To: admin@example.org, ops@example.org\r\n",
+		"multipart/alternative",
+		"text/plain; charset=utf-8",
+		"text/html; charset=utf-8",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Le message devrait contenir %q", want)
+		}
+	}
+}
+
+func TestSendSendmail(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "mail.txt")
+	script := filepath.Join(dir, "sendmail")
+	content := "#!/bin/sh\ncat > " + out + "\n"
+	if err := os.WriteFile(script, []byte(content), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &EmailConfig{To: []string{"root@localhost"}, SendmailPath: script}
+	if err := sendEmail(c, sampleResult()); err != nil {
+		t.Fatalf("sendEmail() a retourné une erreur: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "To: root@localhost") {
+		t.Errorf("sendmail n'a pas reçu le message: %q", data)
+	}
+}
+
+// fakeSMTP accepts one message without TLS nor authentication
+func fakeSMTP(t *testing.T) (string, int, chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					reply("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				inData = true
+				reply("354 Go ahead")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return "127.0.0.1", addr.Port, received
+}
+
+func TestSendSMTP(t *testing.T) {
+	host, port, received := fakeSMTP(t)
+
+	c := &EmailConfig{To: []string{"admin@example.org"}, Method: "smtp", SMTPHost: host, SMTPPort: port}
+	if err := sendEmail(c, sampleResult()); err != nil {
+		t.Fatalf("sendEmail() a retourné une erreur: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if !strings.Contains(msg, "To: admin@example.org") {
+			t.Errorf("Message inattendu: %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Le serveur SMTP n'a reçu aucun message")
+	}
+}
+
+func TestSendSMTP_RequireStartTLS(t *testing.T) {
+	host, port, _ := fakeSMTP(t)
+
+	c := &EmailConfig{To: []string{"a@b"}, Method: "smtp", SMTPHost: host, SMTPPort: port, StartTLS: true}
+	err := sendEmail(c, sampleResult())
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("sendEmail() devrait refuser un serveur sans STARTTLS: %v", err)
+	}
+}
+
+func TestSendSMTP_Stalled(t *testing.T) {
+	// Accepts the connection but never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(5 * time.Second)
+	}()
+	previous := smtpTimeout
+	smtpTimeout = 200 * time.Millisecond
+	defer func() { smtpTimeout = previous }()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	c := &EmailConfig{To: []string{"a@b"}, Method: "smtp", SMTPHost: "127.0.0.1", SMTPPort: addr.Port}
+	start := time.Now()
+	if err := sendEmail(c, sampleResult()); err == nil {
+		t.Error("sendEmail() devrait échouer sur un serveur muet")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("sendEmail() a attendu %v, attendu l'abandon au bout du délai", time.Since(start))
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// Exit codes of the upgrade and resume commands.
+// When several apply, failures win over a required reboot, which wins over warnings.
+const (
+	ExitOK             = 0
+	ExitError          = 1   // unclassified failure
+	ExitUsage          = 2   // invalid command line
+	ExitWarnings       = 10  // completed, but optional steps failed
+	ExitRebootRequired = 11  // completed, a reboot is required
+	ExitNetwork        = 20  // no connectivity, mirror or store unreachable
+	ExitLocked         = 21  // another run kept the lock
+	ExitPackage        = 22  // the package manager failed
+	ExitSnapshot       = 23  // the snapshot could not be created
+	ExitHook           = 24  // a hook failed with "hook_failure": "abort"
+	ExitTimeout        = 25  // a step exceeded its timeout
+	ExitInterrupted    = 130 // stopped by SIGINT or SIGTERM (128 + SIGINT, like the shells)
+)
+
+// Exit codes with their description key, for the man page
+var exitCodes = []struct {
+	code int
+	key  string
+}{
+	{ExitOK, "exit_ok"},
+	{ExitError, "exit_error"},
+	{ExitUsage, "exit_usage"},
+	{ExitWarnings, "exit_warnings"},
+	{ExitRebootRequired, "exit_reboot"},
+	{ExitNetwork, "exit_network"},
+	{ExitLocked, "exit_locked"},
+	{ExitPackage, "exit_package"},
+	{ExitSnapshot, "exit_snapshot"},
+	{ExitHook, "exit_hook"},
+	{ExitTimeout, "exit_timeout"},
+	{ExitInterrupted, "exit_interrupted"},
+}
+
+// Kinds of step errors, recorded in the reports
+const (
+	KindNetwork  = "network"
+	KindLock     = "lock"
+	KindPackage  = "package"
+	KindSnapshot = "snapshot"
+	KindHook     = "hook"
+	KindTimeout  = "timeout"
+)
+
+// StepError is a step failure of a known kind
+type StepError struct {
+	Kind string
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string { return e.Err.Error() }
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// CommandError is a failed command with its output, kept for the classification
+type CommandError struct {
+	Command string
+	Output  string
+	Err     error
+}
+
+func (e *CommandError) Error() string { return e.Err.Error() }
+
+func (e *CommandError) Unwrap() error { return e.Err }
+
+// Output of a package manager waiting for another one
+var dpkgLockPatterns = []string{"could not get lock", "unable to acquire the dpkg frontend lock"}
+
+// classifyError returns the kind of the error of a step
+func classifyError(step string, err error) string {
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		return stepErr.Kind
+	}
+	var locked *errLocked
+	if errors.As(err, &locked) {
+		return KindLock
+	}
+
+	switch {
+	case step == StepInternet:
+		return KindNetwork
+	case step == StepSnapshot:
+		return KindSnapshot
+	case strings.HasPrefix(step, "hook:"):
+		return KindHook
+	}
+
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) && isTransient(cmdErr.Output) {
+		output := strings.ToLower(cmdErr.Output)
+		for _, p := range dpkgLockPatterns {
+			if strings.Contains(output, p) {
+				return KindLock
+			}
+		}
+		return KindNetwork
+	}
+	return KindPackage
+}
+
+// kindExitCode returns the exit code of a failure kind
+func kindExitCode(kind string) int {
+	switch kind {
+	case KindNetwork:
+		return ExitNetwork
+	case KindLock:
+		return ExitLocked
+	case KindPackage:
+		return ExitPackage
+	case KindSnapshot:
+		return ExitSnapshot
+	case KindHook:
+		return ExitHook
+	case KindTimeout:
+		return ExitTimeout
+	}
+	return ExitError
+}
+
+// runExitCode returns the exit code summing up a run
+func runExitCode(r *RunResult) int {
+	if r.Interrupted {
+		return ExitInterrupted
+	}
+	for _, s := range r.Steps {
+		if s.Status == StatusFailed {
+			return kindExitCode(s.Kind)
+		}
+	}
+	// The snapshot is optional for the run, but not for the caller who asked for it
+	for _, s := range r.Steps {
+		if s.Status == StatusWarning && s.Kind == KindSnapshot {
+			return ExitSnapshot
+		}
+	}
+	if r.RebootRequired {
+		return ExitRebootRequired
+	}
+	for _, s := range r.Steps {
+		if s.Status == StatusWarning {
+			return ExitWarnings
+		}
+	}
+	return ExitOK
+}
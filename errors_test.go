@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	cmdErr := func(output string) error {
+		return &CommandError{Command: "sudo apt update", Output: output, Err: errors.New("exit status 100")}
+	}
+	tests := []struct {
+		step     string
+		err      error
+		expected string
+	}{
+		{"internet", errors.New("no connection"), KindNetwork},
+		{"snapshot", cmdErr("timeshift: not enough space"), KindSnapshot},
+		{"hook:pre-upgrade", errors.New("exit status 1"), KindHook},
+		{"apt", cmdErr("Temporary failure resolving 'archive.ubuntu.com'"), KindNetwork},
+		{"apt", cmdErr("E: Could not get lock /var/lib/dpkg/lock-frontend"), KindLock},
+		{"apt", cmdErr("E: Unable to correct problems, you have held broken packages."), KindPackage},
+		{"apt", fmt.Errorf("update: %w", cmdErr("E: Failed to fetch http://archive.ubuntu.com")), KindNetwork},
+		{"snap", &StepError{Kind: KindTimeout, Step: "snap", Err: errors.New("killed")}, KindTimeout},
+		{"upgrade", &errLocked{holder: lockInfo{PID: 1}}, KindLock},
+		{"flatpak", errors.New("exit status 1"), KindPackage},
+	}
+
+	for _, tt := range tests {
+		if got := classifyError(tt.step, tt.err); got != tt.expected {
+			t.Errorf("classifyError(%q, %v) = %q, attendu %q", tt.step, tt.err, got, tt.expected)
+		}
+	}
+}
+
+func TestRunExitCode(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name     string
+		build    func(r *RunResult)
+		expected int
+	}{
+		{"succès", func(r *RunResult) {
+			r.addStep("apt", time.Now(), nil, true)
+		}, ExitOK},
+		{"avertissement", func(r *RunResult) {
+			r.addStep("flatpak", time.Now(), failed, false)
+		}, ExitWarnings},
+		{"redémarrage", func(r *RunResult) {
+			r.addStep("flatpak", time.Now(), failed, false)
+			r.RebootRequired = true
+		}, ExitRebootRequired},
+		{"instantané", func(r *RunResult) {
+			r.addStep("snapshot", time.Now(), failed, false)
+			r.RebootRequired = true
+		}, ExitSnapshot},
+		{"réseau", func(r *RunResult) {
+			r.addStep("internet", time.Now(), failed, true)
+		}, ExitNetwork},
+		{"paquets", func(r *RunResult) {
+			r.addStep("apt", time.Now(), failed, true)
+			r.RebootRequired = true
+		}, ExitPackage},
+		{"délai", func(r *RunResult) {
+			r.addStep("apt", time.Now(), &StepError{Kind: KindTimeout, Step: "apt", Err: failed}, true)
+		}, ExitTimeout},
+		{"interruption", func(r *RunResult) {
+			r.addStep("apt", time.Now(), failed, true)
+			r.Interrupted = true
+		}, ExitInterrupted},
+	}
+
+	for _, tt := range tests {
+		r := newRunResult()
+		tt.build(r)
+		if got := runExitCode(r); got != tt.expected {
+			t.Errorf("%s: runExitCode() = %d, attendu %d", tt.name, got, tt.expected)
+		}
+	}
+}
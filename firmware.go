@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Modes of the firmware step
+const (
+	FirmwareOff   = "off"   // step disabled
+	FirmwareList  = "list"  // refresh the metadata and list the updates
+	FirmwareApply = "apply" // also install the updates
+)
+
+// FirmwareUpdate is a firmware update offered by fwupd for a device
+type FirmwareUpdate struct {
+	Device  string `json:"device"`
+	Current string `json:"current_version"`
+	Version string `json:"version"`
+	// The update is only applied at the next boot
+	NeedsReboot bool `json:"needs_reboot"`
+}
+
+// fwupdDevices is the subset of "fwupdmgr get-updates --json" used by uubu
+type fwupdDevices struct {
+	Devices []struct {
+		Name     string   `json:"Name"`
+		Version  string   `json:"Version"`
+		Flags    []string `json:"Flags"`
+		Releases []struct {
+			Version string   `json:"Version"`
+			Flags   []string `json:"Flags"`
+		} `json:"Releases"`
+	} `json:"Devices"`
+}
+
+// validFirmwareMode tells whether mode is a known firmware mode
+func validFirmwareMode(mode string) bool {
+	switch mode {
+	case FirmwareOff, FirmwareList, FirmwareApply:
+		return true
+	}
+	return false
+}
+
+// hasFlag tells whether flag is in flags
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFwupdUpdates extracts the available updates of "fwupdmgr get-updates --json".
+// The first release of a device is the newest one.
+func parseFwupdUpdates(output string) ([]FirmwareUpdate, error) {
+	// fwupdmgr may print messages before the JSON document
+	if i := strings.Index(output, "{"); i > 0 {
+		output = output[i:]
+	}
+	var doc fwupdDevices
+	if err := json.Unmarshal([]byte(output), &doc); err != nil {
+		return nil, err
+	}
+
+	updates := []FirmwareUpdate{}
+	for _, d := range doc.Devices {
+		if len(d.Releases) == 0 {
+			continue
+		}
+		r := d.Releases[0]
+		reboot := hasFlag(d.Flags, "needs-reboot") || hasFlag(d.Flags, "needs-shutdown") ||
+			hasFlag(r.Flags, "needs-reboot") || hasFlag(r.Flags, "needs-shutdown")
+		updates = append(updates, FirmwareUpdate{
+			Device:      d.Name,
+			Current:     d.Version,
+			Version:     r.Version,
+			NeedsReboot: reboot,
+		})
+	}
+	return updates, nil
+}
+
+// exitCode returns the exit status of a failed command, -1 if it did not run
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// listFirmwareUpdates refreshes the LVFS metadata and lists the available updates
+func listFirmwareUpdates() ([]FirmwareUpdate, error) {
+	// --force: refresh even when the metadata was downloaded recently
+	if output, err := runCommand("sudo", "fwupdmgr", "refresh", "--force"); err != nil {
+		return nil, fmt.Errorf("fwupdmgr refresh: %v: %s", err, strings.TrimSpace(output))
+	}
+
+	output, err := runCommand("fwupdmgr", "get-updates", "--json")
+	if err != nil {
+		// Exit status 2: nothing to do
+		if exitCode(err) == 2 {
+			return []FirmwareUpdate{}, nil
+		}
+		return nil, fmt.Errorf("fwupdmgr get-updates: %v: %s", err, strings.TrimSpace(output))
+	}
+	return parseFwupdUpdates(output)
+}
+
+// updateFirmware runs the firmware step and returns the updates found (mode list)
+// or applied (mode apply)
+func updateFirmware(mode string) ([]FirmwareUpdate, error) {
+	if !commandExists("fwupdmgr") {
+		printMessage(Yellow, getMessage("firmware_missing"))
+		return nil, nil
+	}
+
+	printMessage(Blue, getMessage("checking_firmware"))
+	updates, err := listFirmwareUpdates()
+	if err != nil {
+		printMessage(Yellow, getMessage("firmware_error"))
+		return nil, err
+	}
+	if len(updates) == 0 {
+		printMessage(Green, getMessage("firmware_up_to_date"))
+		return updates, nil
+	}
+
+	printMessage(Blue, getMessage("firmware_available", len(updates)))
+	for _, u := range updates {
+		printMessage("", getMessage("firmware_device", u.Device, u.Current, u.Version))
+	}
+	if mode != FirmwareApply {
+		return updates, nil
+	}
+
+	printMessage(Blue, getMessage("updating_firmware"))
+	// --no-reboot-check: uubu asks for the reboot itself at the end of the run
+	if _, err := runCommand("sudo", "fwupdmgr", "update", "--assume-yes", "--no-reboot-check"); err != nil {
+		printMessage(Yellow, getMessage("firmware_error"))
+		return nil, err
+	}
+
+	printMessage(Green, getMessage("firmware_updated"))
+	return updates, nil
+}
+
+// firmwareNeedsReboot tells whether one of the applied updates waits for a reboot
+func firmwareNeedsReboot(updates []FirmwareUpdate) bool {
+	for _, u := range updates {
+		if u.NeedsReboot {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+func TestParseFwupdUpdates(t *testing.T) {
+	output := `WARNING: UEFI capsule updates not available or enabled in firmware setup
+{
+  "Devices" : [
+    {
+      "Name" : "System Firmware",
+      "Version" : "1.12.0",
+      "Flags" : ["internal", "updatable", "needs-reboot"],
+      "Releases" : [
+        {"Version" : "1.14.1", "Flags" : ["is-upgrade"]},
+        {"Version" : "1.13.0", "Flags" : ["is-upgrade"]}
+      ]
+    },
+    {
+      "Name" : "UNIFYING RECEIVER",
+      "Version" : "RQR12.07",
+      "Flags" : ["updatable"],
+      "Releases" : [{"Version" : "RQR12.10"}]
+    },
+    {
+      "Name" : "Without release",
+      "Version" : "1.0"
+    }
+  ]
+}`
+
+	updates, err := parseFwupdUpdates(output)
+	if err != nil {
+		t.Fatalf("parseFwupdUpdates: %v", err)
+	}
+	expected := []FirmwareUpdate{
+		{Device: "System Firmware", Current: "1.12.0", Version: "1.14.1", NeedsReboot: true},
+		{Device: "UNIFYING RECEIVER", Current: "RQR12.07", Version: "RQR12.10"},
+	}
+	if len(updates) != len(expected) {
+		t.Fatalf("%d mises à jour, attendu %d: %+v", len(updates), len(expected), updates)
+	}
+	for i := range expected {
+		if updates[i] != expected[i] {
+			t.Errorf("Mise à jour %d = %+v, attendu %+v", i, updates[i], expected[i])
+		}
+	}
+	if !firmwareNeedsReboot(updates) {
+		t.Error("Un redémarrage devrait être nécessaire")
+	}
+	if firmwareNeedsReboot(updates[1:]) {
+		t.Error("Aucun redémarrage ne devrait être nécessaire")
+	}
+}
+
+func TestParseFwupdUpdates_Invalid(t *testing.T) {
+	if _, err := parseFwupdUpdates("No updatable devices"); err == nil {
+		t.Error("parseFwupdUpdates() devrait retourner une erreur")
+	}
+}
+
+func TestValidFirmwareMode(t *testing.T) {
+	tests := []struct {
+		mode     string
+		expected bool
+	}{
+		{"off", true},
+		{"list", true},
+		{"apply", true},
+		{"", false},
+		{"yes", false},
+	}
+
+	for _, tt := range tests {
+		if got := validFirmwareMode(tt.mode); got != tt.expected {
+			t.Errorf("validFirmwareMode(%q) = %v, attendu %v", tt.mode, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Default APT frontend
+const defaultAptFrontend = "apt"
+
+// aptFrontend maps the phases of the system step to the invocations of an APT frontend.
+// A nil command skips the phase.
+type aptFrontend struct {
+	update      []string
+	list        []string
+	upgrade     []string
+	fullUpgrade []string
+	autoremove  []string
+	clean       []string
+	// parse returns the upgradable packages of the list output
+	parse func(output string) []string
+}
+
+// aptFrontends are the supported frontends, by program name
+var aptFrontends = map[string]aptFrontend{
+	"apt": {
+		update:      []string{"apt", "update"},
+		list:        []string{"apt", "list", "--upgradable"},
+		upgrade:     []string{"apt", "upgrade", "-y"},
+		fullUpgrade: []string{"apt", "dist-upgrade", "-y"},
+		autoremove:  []string{"apt", "autoremove", "-y"},
+		clean:       []string{"apt", "autoclean"},
+		parse:       parseUpgradable,
+	},
+	"apt-get": {
+		update: []string{"apt-get", "update"},
+		// Simulation: the same resolution as the upgrade, without root
+		list:        []string{"apt-get", "-s", "upgrade"},
+		upgrade:     []string{"apt-get", "upgrade", "-y"},
+		fullUpgrade: []string{"apt-get", "dist-upgrade", "-y"},
+		autoremove:  []string{"apt-get", "autoremove", "-y"},
+		clean:       []string{"apt-get", "autoclean"},
+		parse:       parseAptGetSimulation,
+	},
+	"nala": {
+		update: []string{"nala", "update"},
+		// nala has no script-friendly listing; it resolves like apt
+		list: []string{"apt", "list", "--upgradable"},
+		// "nala upgrade" is a full upgrade unless told otherwise
+		upgrade:     []string{"nala", "upgrade", "-y", "--no-full"},
+		fullUpgrade: []string{"nala", "full-upgrade", "-y"},
+		autoremove:  []string{"nala", "autoremove", "-y"},
+		clean:       []string{"nala", "clean"},
+		parse:       parseUpgradable,
+	},
+	"aptitude": {
+		update:      []string{"aptitude", "update"},
+		list:        []string{"aptitude", "search", "--disable-columns", "-F", "%p %t %V %v", "~U"},
+		upgrade:     []string{"aptitude", "safe-upgrade", "-y"},
+		fullUpgrade: []string{"aptitude", "full-upgrade", "-y"},
+		// aptitude removes the unused packages during its own actions
+		autoremove: nil,
+		clean:      []string{"aptitude", "autoclean"},
+		parse:      parseAptitudeSearch,
+	},
+}
+
+// aptFrontendNames returns the supported frontends, sorted
+func aptFrontendNames() []string {
+	names := make([]string, 0, len(aptFrontends))
+	for name := range aptFrontends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validAptFrontend tells whether name is a supported frontend
+func validAptFrontend(name string) bool {
+	_, ok := aptFrontends[name]
+	return ok
+}
+
+// aptPackage is an upgradable package, whatever the frontend that listed it
+type aptPackage struct {
+	name    string
+	suites  []string
+	version string
+	arch    string
+	current string
+}
+
+// String formats the package as a line of "apt list --upgradable",
+// the format expected by packageName and isSecurityUpdate
+func (p aptPackage) String() string {
+	line := fmt.Sprintf("%s/%s %s", p.name, strings.Join(p.suites, ","), p.version)
+	if p.arch != "" {
+		line += " " + p.arch
+	}
+	if p.current != "" {
+		line += fmt.Sprintf(" [upgradable from: %s]", p.current)
+	}
+	return line
+}
+
+// "Inst libc6 [2.35-0ubuntu3.6] (2.35-0ubuntu3.7 Ubuntu:22.04/jammy-updates, Ubuntu:22.04/jammy-security [amd64])"
+var aptGetInstLine = regexp.MustCompile(`^Inst (\S+) \[([^\]]+)\] \((\S+) (.*) \[([^\]]+)\]\)`)
+
+// parseAptGetSimulation extracts the upgrades of "apt-get -s upgrade".
+// New packages pulled as dependencies have no current version and are left out.
+func parseAptGetSimulation(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		m := aptGetInstLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		p := aptPackage{name: m[1], current: m[2], version: m[3], arch: m[5]}
+		for _, origin := range strings.Split(m[4], ", ") {
+			// "Ubuntu:22.04/jammy-updates": the suite follows the slash
+			if i := strings.LastIndex(origin, "/"); i >= 0 {
+				origin = origin[i+1:]
+			}
+			p.suites = append(p.suites, origin)
+		}
+		lines = append(lines, p.String())
+	}
+	return lines
+}
+
+// parseAptitudeSearch extracts the packages of "aptitude search -F '%p %t %V %v' ~U":
+// name, archives, candidate and installed versions
+func parseAptitudeSearch(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+		name, arch, _ := strings.Cut(fields[0], ":")
+		p := aptPackage{
+			name:    name,
+			suites:  strings.Split(fields[1], ","),
+			version: fields[2],
+			arch:    arch,
+			current: fields[3],
+		}
+		lines = append(lines, p.String())
+	}
+	return lines
+}
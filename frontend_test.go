@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAptGetSimulation(t *testing.T) {
+	output := `Reading package lists...
+Building dependency tree...
+The following packages will be upgraded:
+  libc6 curl
+2 upgraded, 0 newly installed, 0 to remove and 0 not upgraded.
+Inst libc6 [2.35-0ubuntu3.6] (2.35-0ubuntu3.7 Ubuntu:22.04/jammy-updates, Ubuntu:22.04/jammy-security [amd64])
+Inst curl [7.81.0-1ubuntu1.15] (7.81.0-1ubuntu1.16 Ubuntu:22.04/jammy-updates [amd64])
+Inst linux-image-6.8.0-45 (6.8.0-45.45 Ubuntu:22.04/jammy-updates [amd64])
+Conf libc6 (2.35-0ubuntu3.7 Ubuntu:22.04/jammy-updates, Ubuntu:22.04/jammy-security [amd64])
+`
+	expected := []string{
+		"libc6/jammy-updates,jammy-security 2.35-0ubuntu3.7 amd64 [upgradable from: 2.35-0ubuntu3.6]",
+		"curl/jammy-updates 7.81.0-1ubuntu1.16 amd64 [upgradable from: 7.81.0-1ubuntu1.15]",
+	}
+	lines := parseAptGetSimulation(output)
+	if !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("parseAptGetSimulation() = %q, attendu %q", lines, expected)
+	}
+	if !isSecurityUpdate(lines[0]) || isSecurityUpdate(lines[1]) {
+		t.Error("La poche de sécurité devrait être reconnue sur la première ligne seulement")
+	}
+	if packageName(lines[1]) != "curl" {
+		t.Errorf("packageName() = %q, attendu %q", packageName(lines[1]), "curl")
+	}
+}
+
+func TestParseAptitudeSearch(t *testing.T) {
+	output := "libc6 bookworm-security,bookworm 2.36-9+deb12u8 2.36-9+deb12u7\nlibgcc-s1:i386 bookworm 12.2.0-14+deb12u1 12.2.0-14\n\n"
+	expected := []string{
+		"libc6/bookworm-security,bookworm 2.36-9+deb12u8 [upgradable from: 2.36-9+deb12u7]",
+		"libgcc-s1/bookworm 12.2.0-14+deb12u1 i386 [upgradable from: 12.2.0-14]",
+	}
+	lines := parseAptitudeSearch(output)
+	if !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("parseAptitudeSearch() = %q, attendu %q", lines, expected)
+	}
+	if !isSecurityUpdate(lines[0]) {
+		t.Error("La mise à jour de sécurité devrait être reconnue")
+	}
+}
+
+func TestAptFrontends(t *testing.T) {
+	for _, name := range aptFrontendNames() {
+		f := aptFrontends[name]
+		if f.update == nil || f.list == nil || f.upgrade == nil || f.fullUpgrade == nil || f.clean == nil || f.parse == nil {
+			t.Errorf("%s: phase manquante", name)
+		}
+		if got := (aptBackend{frontend: name}).command(); got != name {
+			t.Errorf("command() = %q, attendu %q", got, name)
+		}
+		// Each phase changing the packages must be protected from the signals
+		for _, phase := range [][]string{f.upgrade, f.fullUpgrade, f.autoremove} {
+			if phase != nil && !isTransaction("sudo", phase) {
+				t.Errorf("%s: %v devrait être une transaction", name, phase)
+			}
+		}
+	}
+	if validAptFrontend("yum") {
+		t.Error("yum ne devrait pas être une interface APT valide")
+	}
+	if got := (aptBackend{frontend: "unknown"}).command(); got != "apt" {
+		t.Errorf("Interface inconnue: command() = %q, attendu apt", got)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// historyPath returns the file where runs are recorded, one JSON object per line
+func historyPath(config Config) string {
+	if config.HistoryFile != "" {
+		return config.HistoryFile
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "uubu", "history.jsonl")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "uubu", "history.jsonl")
+	}
+	return filepath.Join(os.TempDir(), "uubu-history.jsonl")
+}
+
+// appendHistory records a run at the end of the history file
+func appendHistory(path string, r *RunResult) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) // #nosec G304 -- path from the configuration
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadHistory reads the recorded runs, oldest first.
+// Unreadable lines are skipped so a truncated write does not hide the history.
+func loadHistory(path string) ([]RunResult, error) {
+	f, err := os.Open(path) // #nosec G304 -- path from the configuration
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []RunResult
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var r RunResult
+		if err := json.Unmarshal(scanner.Bytes(), &r); err == nil {
+			runs = append(runs, r)
+		}
+	}
+	return runs, scanner.Err()
+}
+
+// findRun returns the run with the given ID
+func findRun(runs []RunResult, id string) *RunResult {
+	for i := range runs {
+		if runs[i].ID == id {
+			return &runs[i]
+		}
+	}
+	return nil
+}
+
+// setupHistory defines the history command
+func setupHistory(fs *flag.FlagSet, config *Config) func([]string) int {
+	limit := fs.Int("limit", 10, getMessage("flag_limit"))
+	asJSON := fs.Bool("json", false, getMessage("flag_json"))
+
+	return func(args []string) int {
+		runs, err := loadHistory(historyPath(*config))
+		if err != nil {
+			printMessage(Red, getMessage("error_history", err))
+			return ExitError
+		}
+
+		// Details of a single run
+		if len(args) > 0 {
+			r := findRun(runs, args[0])
+			if r == nil {
+				printMessage(Red, getMessage("history_not_found", args[0]))
+				return ExitUsage
+			}
+			if *asJSON {
+				return printJSON(r)
+			}
+			showRun(r)
+			return 0
+		}
+
+		if *limit > 0 && len(runs) > *limit {
+			runs = runs[len(runs)-*limit:]
+		}
+		if *asJSON {
+			if runs == nil {
+				runs = []RunResult{}
+			}
+			return printJSON(runs)
+		}
+		if len(runs) == 0 {
+			printMessage(Yellow, getMessage("history_empty"))
+			return 0
+		}
+
+		fmt.Printf("%-16s  %-19s  %-8s  %8s  %s\n",
+			getMessage("history_id"), getMessage("history_date"), getMessage("email_status"),
+			getMessage("history_packages"), getMessage("history_reboot"))
+		for i := len(runs) - 1; i >= 0; i-- {
+			r := runs[i]
+			status, color := StatusOK, Green
+			if !r.Success {
+				status, color = StatusFailed, Red
+			} else if len(r.Errors) > 0 {
+				status, color = StatusWarning, Yellow
+			}
+			reboot := ""
+			if r.RebootRequired {
+				reboot = "*"
+			}
+			fmt.Printf("%-16s  %-19s  %s  %8d  %s\n",
+				r.ID, r.StartTime.Format("2006-01-02 15:04:05"), colorize(color, fmt.Sprintf("%-8s", status)), len(r.Upgraded), reboot)
+		}
+		return 0
+	}
+}
+
+// showRun displays the details of a recorded run
+func showRun(r *RunResult) {
+	fmt.Println(r.Summary())
+	fmt.Println()
+	for _, s := range r.Steps {
+		line := fmt.Sprintf("  %-10s %-8s %8s", s.Name, s.Status, s.Duration.Round(time.Second))
+		if s.Error != "" {
+			line += "  " + s.Error
+		}
+		fmt.Println(line)
+	}
+	if len(r.Upgraded) > 0 {
+		fmt.Println()
+		fmt.Println(getMessage("email_upgraded", len(r.Upgraded)))
+		for _, p := range r.Upgraded {
+			fmt.Println("  - " + p)
+		}
+	}
+}
+
+// printJSON prints v as indented JSON
+func printJSON(v interface{}) int {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		printMessage(Red, err.Error())
+		return ExitError
+	}
+	fmt.Println(string(data))
+	return 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistory_AppendAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "history.jsonl")
+
+	first := sampleResult()
+	first.ID = "20250716-100000"
+	second := sampleResult()
+	second.ID = "20250717-100000"
+	second.Success = false
+
+	for _, r := range []*RunResult{first, second} {
+		if err := appendHistory(path, r); err != nil {
+			t.Fatalf("appendHistory() a retourné une erreur: %v", err)
+		}
+	}
+
+	runs, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("loadHistory() a retourné une erreur: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != first.ID || runs[1].Success {
+		t.Fatalf("Historique inattendu: %+v", runs)
+	}
+
+	r := findRun(runs, "20250716-100000")
+	if r == nil || len(r.Upgraded) != 2 {
+		t.Errorf("findRun() devrait retrouver la première exécution: %+v", r)
+	}
+	if findRun(runs, "absent") != nil {
+		t.Error("findRun() ne devrait rien retourner pour un ID inconnu")
+	}
+}
+
+func TestLoadHistory_Missing(t *testing.T) {
+	runs, err := loadHistory(filepath.Join(t.TempDir(), "absent.jsonl"))
+	if err != nil || runs != nil {
+		t.Errorf("loadHistory() sur un fichier absent = %v, %v", runs, err)
+	}
+}
+
+func TestLoadHistory_SkipsBrokenLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	content := `{"id":"a","success":true}
+{"id":"b","succ
+{"id":"c","success":false}
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := loadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[1].ID != "c" {
+		t.Errorf("Les lignes invalides devraient être ignorées: %+v", runs)
+	}
+}
+
+func TestHistoryPath(t *testing.T) {
+	if got := historyPath(Config{HistoryFile: "/tmp/h.jsonl"}); got != "/tmp/h.jsonl" {
+		t.Errorf("historyPath() = %s", got)
+	}
+
+	t.Setenv("XDG_STATE_HOME", "/tmp/state")
+	if got := historyPath(Config{}); got != "/tmp/state/uubu/history.jsonl" {
+		t.Errorf("historyPath() = %s", got)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Default directory holding the <phase>.d hook directories
+const defaultHooksDir = "/etc/uubu/hooks"
+
+// Behaviors when a hook fails
+const (
+	HookAbort = "abort" // stop the run (or the reboot)
+	HookWarn  = "warn"  // report the failure and go on
+)
+
+// Names accepted by run-parts: no dots, so that package leftovers are ignored
+var hookName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// hookScripts returns the hooks of a phase in lexical order
+func hookScripts(dir, phase string) []string {
+	if dir == "" {
+		return nil
+	}
+	phaseDir := filepath.Join(dir, phase+".d")
+	entries, err := os.ReadDir(phaseDir)
+	if err != nil {
+		return nil
+	}
+
+	var scripts []string
+	for _, e := range entries {
+		if !hookName.MatchString(e.Name()) {
+			continue
+		}
+		path := filepath.Join(phaseDir, e.Name())
+		if info, err := os.Stat(path); err == nil && safeExecutable(info) {
+			scripts = append(scripts, path)
+		}
+	}
+	sort.Strings(scripts)
+	return scripts
+}
+
+// hookEnv returns the variables describing the run to the hooks
+func hookEnv(result *RunResult, phase, step, contextFile string) []string {
+	return []string{
+		"UUBU_HOOK_PHASE=" + phase,
+		"UUBU_HOOK_STEP=" + step,
+		"UUBU_RUN_ID=" + result.ID,
+		"UUBU_VERSION=" + version,
+		"UUBU_CONTEXT_FILE=" + contextFile,
+		"UUBU_SUCCESS=" + strconv.FormatBool(result.Success),
+		"UUBU_REBOOT_REQUIRED=" + strconv.FormatBool(result.RebootRequired),
+		"UUBU_UPGRADED_COUNT=" + strconv.Itoa(len(result.Upgraded)),
+	}
+}
+
+// writeHookContext writes the run so far to a temporary JSON file
+func writeHookContext(result *RunResult) (string, error) {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("", "uubu-hook-*.json")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// runHooks runs the hooks of a phase, run-parts style, and records them as a step.
+// step is the step surrounded by the phase, empty for the run-wide phases.
+// It returns an error only when a hook failed and the configuration asks to abort.
+func runHooks(config Config, result *RunResult, phase, step string) error {
+	scripts := hookScripts(config.HooksDir, phase)
+	if len(scripts) == 0 {
+		return nil
+	}
+
+	contextFile, err := writeHookContext(result)
+	if err != nil {
+		printMessage(Yellow, getMessage("hook_context_error", err))
+	} else {
+		defer os.Remove(contextFile)
+	}
+
+	printMessage(Blue, getMessage("running_hooks", phase, len(scripts)))
+	start := time.Now()
+	var failure error
+	for _, script := range scripts {
+		// Shown as it comes and kept in the run log, never retried
+		streams := commandIO{env: hookEnv(result, phase, step, contextFile), show: true, once: true}
+		if _, err := runWithPolicy(streams, script); err != nil {
+			failure = fmt.Errorf("%s: %v", filepath.Base(script), err)
+			printMessage(Yellow, getMessage("hook_failed", filepath.Base(script), err))
+			// Like run-parts --exit-on-error when aborting
+			if config.HookFailure == HookAbort {
+				break
+			}
+		}
+	}
+
+	abort := failure != nil && config.HookFailure == HookAbort
+	result.addStep("hook:"+phase, start, failure, abort)
+	if abort {
+		return errors.New(getMessage("hook_aborted", phase, failure))
+	}
+	return nil
+}
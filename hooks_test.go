@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeHook installs a hook script in dir/<phase>.d
+func writeHook(t *testing.T, dir, phase, name, script string, mode os.FileMode) {
+	t.Helper()
+	phaseDir := filepath.Join(dir, phase+".d")
+	if err := os.MkdirAll(phaseDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(phaseDir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHookScripts(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, "pre-upgrade", "20-smoke", "", 0o755)
+	writeHook(t, dir, "pre-upgrade", "10-stop_services", "", 0o755)
+	writeHook(t, dir, "pre-upgrade", "30-disabled", "", 0o644)
+	writeHook(t, dir, "pre-upgrade", "40-open", "", 0o777)
+	writeHook(t, dir, "pre-upgrade", "50-backup.sh", "", 0o755)
+	writeHook(t, dir, "pre-upgrade", "60-old.dpkg-old", "", 0o755)
+
+	var names []string
+	for _, s := range hookScripts(dir, "pre-upgrade") {
+		names = append(names, filepath.Base(s))
+	}
+	if got := strings.Join(names, ","); got != "10-stop_services,20-smoke" {
+		t.Errorf("hookScripts() = %q, attendu %q", got, "10-stop_services,20-smoke")
+	}
+	if scripts := hookScripts(dir, "post-upgrade"); scripts != nil {
+		t.Errorf("Aucun hook attendu pour une phase sans répertoire: %v", scripts)
+	}
+	if scripts := hookScripts("", "pre-upgrade"); scripts != nil {
+		t.Errorf("Aucun hook attendu sans répertoire de hooks: %v", scripts)
+	}
+}
+
+func TestRunHooks_Context(t *testing.T) {
+	if !commandExists("sh") {
+		t.Skip("sh non installé")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(t.TempDir(), "env")
+	writeHook(t, dir, "post-apt", "10-env",
+		`echo "$UUBU_HOOK_PHASE $UUBU_HOOK_STEP $UUBU_RUN_ID $UUBU_UPGRADED_COUNT" > `+out+`; cp "$UUBU_CONTEXT_FILE" `+out+`.json`, 0o755)
+
+	config := defaultConfig()
+	config.HooksDir = dir
+	result := newRunResult()
+	result.Upgraded = []string{"curl", "vim"}
+
+	if err := runHooks(config, result, "post-apt", "apt"); err != nil {
+		t.Fatalf("runHooks: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "post-apt apt " + result.ID + " 2"
+	if got := strings.TrimSpace(string(data)); got != expected {
+		t.Errorf("Environnement du hook = %q, attendu %q", got, expected)
+	}
+
+	data, err = os.ReadFile(out + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var context RunResult
+	if err := json.Unmarshal(data, &context); err != nil || len(context.Upgraded) != 2 {
+		t.Errorf("Contexte JSON invalide: %v %+v", err, context)
+	}
+
+	if len(result.Steps) != 1 || result.Steps[0].Name != "hook:post-apt" || result.Steps[0].Status != StatusOK {
+		t.Errorf("Étape des hooks incorrecte: %+v", result.Steps)
+	}
+}
+
+func TestRunHooks_Output(t *testing.T) {
+	if !commandExists("sh") {
+		t.Skip("sh non installé")
+	}
+	dir := t.TempDir()
+	writeHook(t, dir, "pre-upgrade", "10-echo", `echo "services arrêtés"; echo "avertissement" >&2`, 0o755)
+
+	config := defaultConfig()
+	config.HooksDir = dir
+	config.LogFile = filepath.Join(t.TempDir(), "uubu.log")
+	config.Journald = false
+	result := newRunResult()
+	l := startRunLog(config, result)
+	err := runHooks(config, result, "pre-upgrade", "")
+	l.close()
+	if err != nil {
+		t.Fatalf("runHooks: %v", err)
+	}
+
+	data, _ := os.ReadFile(config.LogFile)
+	for _, want := range []string{"10-echo: exit 0", "    services arrêtés\n", "    avertissement\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Sortie du hook absente du journal (%q):\n%s", want, data)
+		}
+	}
+}
+
+func TestRunHooks_Failure(t *testing.T) {
+	if !commandExists("sh") {
+		t.Skip("sh non installé")
+	}
+	dir := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "second")
+	writeHook(t, dir, "pre-upgrade", "10-fail", "exit 3", 0o755)
+	writeHook(t, dir, "pre-upgrade", "20-next", "touch "+marker, 0o755)
+
+	testCases := []struct {
+		behavior   string
+		wantErr    bool
+		wantStatus string
+		wantNext   bool
+	}{
+		{HookWarn, false, StatusWarning, true},
+		{HookAbort, true, StatusFailed, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.behavior, func(t *testing.T) {
+			os.Remove(marker)
+			config := defaultConfig()
+			config.HooksDir = dir
+			config.HookFailure = tc.behavior
+			result := newRunResult()
+
+			err := runHooks(config, result, "pre-upgrade", "")
+			if (err != nil) != tc.wantErr {
+				t.Errorf("runHooks() erreur = %v, attendu erreur: %v", err, tc.wantErr)
+			}
+			if result.Steps[0].Status != tc.wantStatus {
+				t.Errorf("Statut = %s, attendu %s", result.Steps[0].Status, tc.wantStatus)
+			}
+			if _, err := os.Stat(marker); (err == nil) != tc.wantNext {
+				t.Errorf("Le hook suivant exécuté: %v, attendu %v", err == nil, tc.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runJournal records the completed steps of a run so that an interrupted run
+// can be resumed. It is removed when the run completes.
+type runJournal struct {
+	RunID     string    `json:"run_id"`
+	StartTime time.Time `json:"start_time"`
+	// Options of the run, reused by "uubu resume"
+	Config      Config   `json:"config"`
+	Completed   []string `json:"completed_steps"`
+	Interrupted bool     `json:"interrupted"`
+
+	path string
+}
+
+// journalPath returns the journal file, next to the history
+func journalPath(config Config) string {
+	return filepath.Join(filepath.Dir(historyPath(config)), "journal.json")
+}
+
+// newJournal starts the journal of a run
+func newJournal(path string, config Config, result *RunResult) *runJournal {
+	return &runJournal{
+		RunID:     result.ID,
+		StartTime: result.StartTime,
+		Config:    config,
+		Completed: []string{},
+		path:      path,
+	}
+}
+
+// loadJournal reads the journal of an unfinished run, nil if there is none
+func loadJournal(path string) (*runJournal, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path from the configuration
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var j runJournal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	j.path = path
+	return &j, nil
+}
+
+// done tells whether a step was completed before the interruption
+func (j *runJournal) done(step string) bool {
+	for _, s := range j.Completed {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// complete records a finished step
+func (j *runJournal) complete(step string) {
+	j.Completed = append(j.Completed, step)
+	j.save()
+}
+
+// save writes the journal atomically; the configuration it holds may contain secrets
+func (j *runJournal) save() {
+	err := os.MkdirAll(filepath.Dir(j.path), 0o700)
+	if err == nil {
+		var data []byte
+		if data, err = json.MarshalIndent(j, "", "  "); err == nil {
+			tmp := j.path + ".tmp"
+			if err = os.WriteFile(tmp, data, 0o600); err == nil {
+				err = os.Rename(tmp, j.path)
+			}
+		}
+	}
+	if err != nil {
+		printMessage(Yellow, getMessage("error_journal", err))
+	}
+}
+
+// remove deletes the journal of a completed run
+func (j *runJournal) remove() {
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		printMessage(Yellow, getMessage("error_journal", err))
+	}
+}
+
+// setupResume defines the resume command
+func setupResume(fs *flag.FlagSet, config *Config) func([]string) int {
+	return func([]string) int {
+		journal, err := loadJournal(journalPath(*config))
+		if err != nil {
+			printMessage(Red, getMessage("error_journal", err))
+			return ExitError
+		}
+		if journal == nil {
+			printMessage(Green, getMessage("resume_nothing"))
+			return 0
+		}
+
+		completed := strings.Join(journal.Completed, ", ")
+		if completed == "" {
+			completed = "-"
+		}
+		printMessage(Blue, getMessage("resume_run", journal.RunID, completed))
+		return runUpgrade(journal.Config, journal)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRunJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "journal.json")
+	config := defaultConfig()
+	config.DistUpgrade = true
+	result := newRunResult()
+
+	j := newJournal(path, config, result)
+	j.complete("snapshot")
+	j.complete("apt")
+
+	loaded, err := loadJournal(path)
+	if err != nil || loaded == nil {
+		t.Fatalf("loadJournal: %v, %v", loaded, err)
+	}
+	if loaded.RunID != result.ID || !loaded.Config.DistUpgrade {
+		t.Errorf("Journal relu incorrect: %+v", loaded)
+	}
+	if !loaded.done("apt") || loaded.done("snap") {
+		t.Errorf("Étapes terminées incorrectes: %v", loaded.Completed)
+	}
+
+	loaded.remove()
+	if j, err := loadJournal(path); j != nil || err != nil {
+		t.Errorf("Le journal devrait être supprimé: %v, %v", j, err)
+	}
+}
+
+func TestResume_Nothing(t *testing.T) {
+	config := defaultConfig()
+	config.HistoryFile = filepath.Join(t.TempDir(), "history.jsonl")
+
+	if code := runCLI(config, nil, []string{"resume"}); code != 0 {
+		t.Errorf("resume sans journal = %d, attendu 0", code)
+	}
+}
+
+func TestJournalPath(t *testing.T) {
+	config := defaultConfig()
+	config.HistoryFile = "/var/lib/uubu/history.jsonl"
+	if got := journalPath(config); got != "/var/lib/uubu/journal.json" {
+		t.Errorf("journalPath() = %q, attendu %q", got, "/var/lib/uubu/journal.json")
+	}
+}
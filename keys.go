@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keyrings trusted for every source, directory of the per-repository keyrings
+// and directory of the downloaded Release files
+var (
+	aptTrustedDir  = "/etc/apt/trusted.gpg.d"
+	aptKeyringsDir = "/etc/apt/keyrings"
+	aptListsDir    = "/var/lib/apt/lists"
+)
+
+// Kinds of signing key problems
+const (
+	RepoKeyExpiring    = "key-expiring"
+	RepoMissingKeyring = "missing-keyring"
+)
+
+// Default warning delay before a key expires, in days
+const defaultKeyExpiryDays = 30
+
+// aptKey is an OpenPGP key of a keyring
+type aptKey struct {
+	Fingerprint string
+	UID         string
+	// Last day a signature can be verified, zero when the key never expires
+	Expires time.Time
+	// Expired or revoked primary key, or no usable signing key left
+	Expired bool
+}
+
+// KeyID returns the long key ID, as printed by apt
+func (k aptKey) KeyID() string {
+	if len(k.Fingerprint) > 16 {
+		return k.Fingerprint[len(k.Fingerprint)-16:]
+	}
+	return k.Fingerprint
+}
+
+// parseGPGColons reads the keys of "gpg --show-keys --with-colons".
+// A key expires with its primary key, or with the last of its signing keys.
+func parseGPGColons(output string) []aptKey {
+	var keys []aptKey
+	var current *aptKey
+	// Latest expiry of the signing keys, and whether one never expires or is unusable
+	var signing time.Time
+	var signingForever, hasSigning, primaryExpired bool
+	var primary time.Time
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Expires = primary
+		switch {
+		case !hasSigning:
+			current.Expired = true
+		case !signingForever && (primary.IsZero() || signing.Before(primary)):
+			current.Expires = signing
+		}
+		current.Expired = current.Expired || primaryExpired
+		keys = append(keys, *current)
+		current = nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		f := strings.Split(strings.TrimSpace(line), ":")
+		if len(f) < 10 || ((f[0] == "pub" || f[0] == "sub") && len(f) < 12) {
+			continue
+		}
+		switch f[0] {
+		case "pub":
+			flush()
+			current = &aptKey{}
+			primary, signing = parseEpoch(f[6]), time.Time{}
+			primaryExpired = f[1] == "e" || f[1] == "r"
+			signingForever, hasSigning = false, false
+			fallthrough
+		case "sub":
+			if current == nil || !strings.Contains(f[11], "s") || f[1] == "e" || f[1] == "r" {
+				continue
+			}
+			hasSigning = true
+			if expires := parseEpoch(f[6]); expires.IsZero() {
+				signingForever = true
+			} else if expires.After(signing) {
+				signing = expires
+			}
+		case "fpr":
+			if current != nil && current.Fingerprint == "" {
+				current.Fingerprint = f[9]
+			}
+		case "uid":
+			if current != nil && current.UID == "" {
+				current.UID = f[9]
+			}
+		}
+	}
+	flush()
+	return keys
+}
+
+// parseEpoch reads a timestamp of the colon format, zero when empty
+func parseEpoch(field string) time.Time {
+	seconds, err := strconv.ParseInt(field, 10, 64)
+	if err != nil || seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+// gpgCommand returns the gpg invocation using a throwaway home directory:
+// inspecting the keyrings must not touch the keys of the user
+func gpgCommand(home string, args ...string) []string {
+	return append([]string{"gpg", "--homedir", home, "--batch", "--quiet"}, args...)
+}
+
+// keyringKeys lists the keys of a keyring file, binary or armored
+func keyringKeys(home, path string) ([]aptKey, error) {
+	cmd := gpgCommand(home, "--show-keys", "--with-colons", path)
+	output, err := runCommand(cmd[0], cmd[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return parseGPGColons(output), nil
+}
+
+// checkedKeyrings returns the keyrings to inspect: the global ones, and those
+// referenced by the enabled sources, wherever they live (/usr/share/keyrings...).
+// Unreferenced keyrings are not used by APT and are left out.
+func checkedKeyrings(sources []aptSource) []string {
+	seen := make(map[string]bool)
+	var keyrings []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			keyrings = append(keyrings, path)
+		}
+	}
+	for _, pattern := range []string{"*.gpg", "*.asc"} {
+		matches, _ := filepath.Glob(filepath.Join(aptTrustedDir, pattern))
+		for _, path := range matches {
+			add(path)
+		}
+	}
+	for _, s := range sources {
+		if s.Enabled && s.SignedBy != "" {
+			add(s.SignedBy)
+		}
+	}
+	sort.Strings(keyrings)
+	return keyrings
+}
+
+// keyIssues returns the expired keys and the keys expiring within days of now
+func keyIssues(path string, keys []aptKey, days int, now time.Time) []repoIssue {
+	var issues []repoIssue
+	limit := now.AddDate(0, 0, days)
+	for _, k := range keys {
+		switch {
+		case k.Expired || (!k.Expires.IsZero() && k.Expires.Before(now)):
+			issues = append(issues, repoIssue{Kind: RepoExpiredKey, URI: path, Detail: k.KeyID()})
+		case !k.Expires.IsZero() && k.Expires.Before(limit):
+			issues = append(issues, repoIssue{Kind: RepoKeyExpiring, URI: path, Detail: k.KeyID(),
+				Expires: k.Expires.Format("2006-01-02")})
+		}
+	}
+	return issues
+}
+
+// checkKeys inspects the signing keys of the sources, nothing when gpg is missing
+func checkKeys(sources []aptSource, days int) []repoIssue {
+	var issues []repoIssue
+	for _, s := range sources {
+		if !s.Enabled || s.SignedBy == "" {
+			continue
+		}
+		if _, err := os.Stat(s.SignedBy); os.IsNotExist(err) {
+			issues = append(issues, repoIssue{Kind: RepoMissingKeyring, URI: strings.TrimSuffix(s.URIs[0], "/"),
+				Suite: firstOf(s.Suites), Detail: s.SignedBy})
+		}
+	}
+	if !commandExists("gpg") {
+		return issues
+	}
+
+	home, err := os.MkdirTemp("", "uubu-gpg-")
+	if err != nil {
+		return issues
+	}
+	defer os.RemoveAll(home)
+	now := time.Now()
+	for _, path := range checkedKeyrings(sources) {
+		keys, err := keyringKeys(home, path)
+		if err != nil {
+			continue
+		}
+		issues = append(issues, keyIssues(path, keys, days, now)...)
+	}
+	return issues
+}
+
+// firstOf returns the first value, empty when there is none
+func firstOf(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// aptListFile returns the name under which APT stores a file of the repository,
+// "http://host/path/" becoming "host_path_dists_<suite>_<name>"
+func aptListFile(uri, suite, name string) string {
+	if i := strings.Index(uri, "://"); i >= 0 {
+		uri = uri[i+3:]
+	}
+	// Credentials are not part of the name
+	if i := strings.Index(uri, "@"); i >= 0 {
+		uri = uri[i+1:]
+	}
+	uri = strings.TrimSuffix(uri, "/")
+	return strings.ReplaceAll(uri+"/dists/"+suite+"/"+name, "/", "_")
+}
+
+// signedBy tells whether the keyring signs the Release file downloaded for the source
+func signedBy(keyring string, s aptSource) bool {
+	base := filepath.Join(aptListsDir, aptListFile(s.URIs[0], firstOf(s.Suites), ""))
+	if _, err := os.Stat(base + "InRelease"); err == nil {
+		_, err := runCommand("gpgv", "--keyring", keyring, base+"InRelease")
+		return err == nil
+	}
+	_, err := runCommand("gpgv", "--keyring", keyring, base+"Release.gpg", base+"Release")
+	return err == nil
+}
+
+// signedByCommand returns the command adding the signed-by option to a source:
+// inside the options of a one-line entry, as a field of a deb822 stanza
+func signedByCommand(s aptSource, keyring string) []string {
+	if s.Deb822 {
+		return []string{"sudo", "sed", "-i", "-e", fmt.Sprintf("%da Signed-By: %s", s.Line, keyring), s.File}
+	}
+	line := strconv.Itoa(s.Line)
+	withOptions := line + `s|^\(\s*deb\(-src\)\?\)\s\+\[|\1 [signed-by=` + keyring + ` |`
+	withoutOptions := line + `s|^\(\s*deb\(-src\)\?\)\s\+\([^[ ]\)|\1 [signed-by=` + keyring + `] \3|`
+	return []string{"sudo", "sed", "-i", "-e", withOptions, "-e", withoutOptions, s.File}
+}
+
+// keyringName returns the per-repository keyring of a source: the host of the repository
+func keyringName(s aptSource, fingerprint string) string {
+	host := s.URIs[0]
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	host, _, _ = strings.Cut(host, "/")
+	path := filepath.Join(aptKeyringsDir, host+".gpg")
+	if _, err := os.Stat(path); err == nil {
+		// Another key of the same host
+		path = filepath.Join(aptKeyringsDir, host+"-"+aptKey{Fingerprint: fingerprint}.KeyID()+".gpg")
+	}
+	return path
+}
+
+// migrateLegacyKeys moves each key of the legacy trusted.gpg keyring that signs
+// enabled sources to its own keyring, referenced by signed-by from these sources,
+// then removes it from trusted.gpg. Keys signing no source are left in place.
+func migrateLegacyKeys() error {
+	if _, err := os.Stat(aptTrustedGPG); err != nil {
+		printMessage(Green, getMessage("keys_no_legacy"))
+		return nil
+	}
+	home, err := os.MkdirTemp("", "uubu-gpg-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(home)
+
+	keys, err := keyringKeys(home, aptTrustedGPG)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		// Reloaded for each key: the edits of the previous one moved the lines
+		var candidates []aptSource
+		for _, s := range loadSources() {
+			if s.Enabled && s.SignedBy == "" && len(s.Suites) > 0 {
+				candidates = append(candidates, s)
+			}
+		}
+		exported := filepath.Join(home, k.Fingerprint+".gpg")
+		cmd := gpgCommand(home, "--no-default-keyring", "--keyring", aptTrustedGPG,
+			"--output", exported, "--export", k.Fingerprint)
+		if _, err := runCommand(cmd[0], cmd[1:]...); err != nil {
+			return err
+		}
+		var signed []aptSource
+		for _, s := range candidates {
+			if signedBy(exported, s) {
+				signed = append(signed, s)
+			}
+		}
+		if len(signed) == 0 {
+			printMessage(Yellow, getMessage("keys_unused", k.KeyID(), k.UID))
+			continue
+		}
+
+		keyring := keyringName(signed[0], k.Fingerprint)
+		if _, err := runCommand("sudo", "install", "-D", "-m", "644", exported, keyring); err != nil {
+			return err
+		}
+		sortBottomUp(signed)
+		for _, s := range signed {
+			cmd := signedByCommand(s, keyring)
+			if _, err := runCommand(cmd[0], cmd[1:]...); err != nil {
+				return err
+			}
+		}
+		// Removed last: until now, the sources still verify with trusted.gpg
+		cmd = gpgCommand(home, "--no-default-keyring", "--keyring", aptTrustedGPG,
+			"--yes", "--delete-keys", k.Fingerprint)
+		if _, err := runCommand("sudo", cmd...); err != nil {
+			return err
+		}
+		printMessage(Green, getMessage("keys_migrated", k.KeyID(), keyring, len(signed)))
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Default lock file, shared by all the users of the machine
+const defaultLockFile = "/run/lock/uubu.lock"
+
+// Delay between two attempts while waiting for another run
+var lockPollInterval = time.Second
+
+// lockInfo is the content of the lock file, naming the holder
+type lockInfo struct {
+	PID       int       `json:"pid"`
+	User      string    `json:"user"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// runLock is a lock held by this process
+type runLock struct {
+	file *os.File
+}
+
+// errLocked reports a lock held by another run
+type errLocked struct {
+	holder lockInfo
+}
+
+func (e *errLocked) Error() string {
+	return getMessage("lock_held", e.holder.PID, e.holder.User, e.holder.StartTime.Format("2006-01-02 15:04:05"))
+}
+
+// readLock returns the holder recorded in a lock file
+func readLock(path string) (lockInfo, error) {
+	var info lockInfo
+	data, err := os.ReadFile(path) // #nosec G304 -- path from the configuration
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(data, &info)
+	return info, err
+}
+
+// openLock opens the lock file, read-only when it belongs to another user:
+// flock only needs a descriptor
+func openLock(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644) // #nosec G302 G304 -- readable to name the holder
+	if os.IsPermission(err) {
+		f, err = os.Open(path) // #nosec G304 -- path from the configuration
+	}
+	return f, err
+}
+
+// tryLock takes the lock with flock(2). The file is never deleted, so that
+// two runs cannot lock two different files; the kernel releases the lock
+// of a process that died. The PID, user and start time written in the file
+// only name the holder to the other runs.
+func tryLock(path string) (*runLock, error) {
+	f, err := openLock(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			// The holder may not have written its name yet
+			holder, _ := readLock(path)
+			return nil, &errLocked{holder: holder}
+		}
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	info := lockInfo{PID: os.Getpid(), StartTime: time.Now()}
+	if u, err := user.Current(); err == nil {
+		info.User = u.Username
+	}
+	if data, err := json.Marshal(info); err == nil && f.Truncate(0) == nil {
+		_, _ = f.WriteAt(data, 0)
+	}
+	return &runLock{file: f}, nil
+}
+
+// lockHolder returns the run holding the lock, false when it is free
+func lockHolder(path string) (lockInfo, bool) {
+	f, err := os.Open(path) // #nosec G304 -- path from the configuration
+	if err != nil {
+		return lockInfo{}, false
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err == nil {
+		return lockInfo{}, false
+	}
+	holder, _ := readLock(path)
+	return holder, true
+}
+
+// acquireLock takes the lock, waiting up to wait for another run to finish
+func acquireLock(path string, wait time.Duration) (*runLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	announced := false
+	for {
+		lock, err := tryLock(path)
+		var locked *errLocked
+		if !errors.As(err, &locked) || time.Now().After(deadline) {
+			return lock, err
+		}
+		if !announced {
+			printMessage(Yellow, locked.Error())
+			printMessage(Blue, getMessage("lock_waiting"))
+			announced = true
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// release clears the holder and releases the lock, keeping the file
+func (l *runLock) release() {
+	if l == nil || l.file == nil {
+		return
+	}
+	_ = l.file.Truncate(0)
+	l.file.Close()
+	l.file = nil
+}
+
+// lockExitCode returns the exit code of a failure to take the lock
+func lockExitCode(err error) int {
+	var locked *errLocked
+	if errors.As(err, &locked) {
+		return ExitLocked
+	}
+	return ExitError
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcquireLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uubu.lock")
+
+	lock, err := acquireLock(path, 0)
+	if err != nil {
+		t.Fatalf("acquireLock: %v", err)
+	}
+	holder, err := readLock(path)
+	if err != nil || holder.PID != os.Getpid() {
+		t.Errorf("Le verrou devrait nommer ce processus: %+v, %v", holder, err)
+	}
+
+	// Held by a live process (this one): refused
+	_, err = acquireLock(path, 0)
+	var locked *errLocked
+	if !errors.As(err, &locked) || locked.holder.PID != os.Getpid() {
+		t.Errorf("Un second verrou devrait être refusé, obtenu %v", err)
+	}
+
+	if holder, held := lockHolder(path); !held || holder.PID != os.Getpid() {
+		t.Errorf("lockHolder() = %+v, %v, attendu ce processus", holder, held)
+	}
+
+	lock.release()
+	if _, held := lockHolder(path); held {
+		t.Error("Le verrou devrait être libre après release()")
+	}
+	// The file stays: a run deleting it would let two runs lock two files
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Le fichier de verrou devrait être conservé: %v", err)
+	}
+	again, err := acquireLock(path, 0)
+	if err != nil {
+		t.Fatalf("Le verrou libéré devrait être repris: %v", err)
+	}
+	again.release()
+}
+
+func TestAcquireLock_Stale(t *testing.T) {
+	// PID of a process that has exited
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip("true non disponible")
+	}
+	deadPID := cmd.Process.Pid
+
+	path := filepath.Join(t.TempDir(), "uubu.lock")
+	data, _ := json.Marshal(lockInfo{PID: deadPID, User: "someone", StartTime: time.Now().Add(-time.Hour)})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := acquireLock(path, 0)
+	if err != nil {
+		t.Fatalf("Un verrou obsolète devrait être remplacé: %v", err)
+	}
+	defer lock.release()
+	if holder, _ := readLock(path); holder.PID != os.Getpid() {
+		t.Errorf("Détenteur = %d, attendu %d", holder.PID, os.Getpid())
+	}
+}
+
+func TestAcquireLock_Wait(t *testing.T) {
+	previous := lockPollInterval
+	lockPollInterval = 10 * time.Millisecond
+	defer func() { lockPollInterval = previous }()
+
+	path := filepath.Join(t.TempDir(), "uubu.lock")
+	first, err := acquireLock(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		first.release()
+	}()
+
+	second, err := acquireLock(path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("L'attente devrait aboutir après la libération: %v", err)
+	}
+	second.release()
+
+	// Timeout while the other run keeps the lock
+	third, err := acquireLock(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.release()
+	start := time.Now()
+	if _, err := acquireLock(path, 50*time.Millisecond); err == nil {
+		t.Error("L'attente devrait expirer")
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("L'attente devrait durer au moins le délai demandé")
+	}
+}
+
+func TestAcquireLock_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uubu.lock")
+	// Left by a run that died: not locked any more
+	data, _ := json.Marshal(lockInfo{PID: 1, User: "someone"})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	const runs = 20
+	var wg sync.WaitGroup
+	var holders atomic.Int32
+	locks := make(chan *runLock, runs)
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if lock, err := tryLock(path); err == nil {
+				holders.Add(1)
+				locks <- lock
+			}
+		}()
+	}
+	wg.Wait()
+	close(locks)
+	for lock := range locks {
+		lock.release()
+	}
+	if holders.Load() != 1 {
+		t.Errorf("%d runs ont pris le verrou, attendu 1", holders.Load())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -39,13 +40,6 @@ var (
 //go:embed locales/*.json
 var localesFS embed.FS
 
-type Config struct {
-	CreateSnapshot    bool
-	UpdateSnap        bool
-	UpdateFlatpak     bool
-	CheckRebootNeeded bool
-}
-
 // loadLanguage loads messages for a given language
 func loadLanguage(lang string) error {
 	filename := fmt.Sprintf("locales/%s.json", lang)
@@ -112,9 +106,14 @@ func getMessage(key string, args ...interface{}) string {
 	return fmt.Sprintf("[MISSING: %s]", key)
 }
 
-// printMessage displays a message with a given color
+// printMessage displays a message with a given color and records it in the run log.
+// In quiet mode only the warnings (yellow) and errors (red) are shown.
 func printMessage(color, message string) {
-	fmt.Printf("%s%s%s\n", color, message, NC)
+	activeLog.message(color, message)
+	if verbosity == LevelQuiet && color != Red && color != Yellow {
+		return
+	}
+	fmt.Println(colorize(color, message))
 }
 
 // checkRoot checks if the user is root
@@ -162,9 +161,8 @@ func createSnapshot() error {
 	if strings.ContainsAny(comment, ";|&`$(){}[]<>") {
 		comment = "System update snapshot - " + time.Now().Format("2006-01-02 15:04")
 	}
-	cmd := exec.Command("sudo", "timeshift", "--create", "--comments", comment, "--scripted") // #nosec G204 -- comment validated and from controlled locale files
-
-	if err := cmd.Run(); err != nil {
+	// Started in its own process group by the runner: Ctrl-C does not stop it halfway
+	if _, err := runCommand("sudo", "timeshift", "--create", "--comments", comment, "--scripted"); err != nil {
 		printMessage(Yellow, getMessage("snapshot_failed"))
 		return err
 	}
@@ -173,11 +171,10 @@ func createSnapshot() error {
 	return nil
 }
 
-// runCommand executes a command and returns its output
+// runCommand executes a command and returns its output.
+// During a step, the timeout and the retries of the step apply.
 func runCommand(name string, args ...string) (string, error) {
-	cmd := exec.Command(name, args...)
-	output, err := cmd.CombinedOutput()
-	return string(output), err
+	return runWithPolicy(commandIO{}, name, args...)
 }
 
 // commandExists checks if a command exists
@@ -186,24 +183,8 @@ func commandExists(cmd string) bool {
 	return err == nil
 }
 
-// updateSystem performs the main system update
-func updateSystem(distUpgrade bool) error {
-	printMessage(Blue, getMessage("update_start"))
-
-	// Update the package list
-	printMessage(Blue, getMessage("update_packages"))
-	if _, err := runCommand("sudo", "apt", "update"); err != nil {
-		printMessage(Red, getMessage("update_error"))
-		return err
-	}
-
-	// Checking for packages to update
-	output, err := runCommand("apt", "list", "--upgradable")
-	if err != nil {
-		printMessage(Red, getMessage("check_packages"))
-		return err
-	}
-
+// parseUpgradable extracts the package lines of "apt list --upgradable"
+func parseUpgradable(output string) []string {
 	lines := strings.Split(strings.TrimSpace(output), "\n")
 	// Filter empty lines and the header line
 	var upgradableLines []string
@@ -214,73 +195,74 @@ func updateSystem(distUpgrade bool) error {
 			upgradableLines = append(upgradableLines, line)
 		}
 	}
+	return upgradableLines
+}
+
+// packageName returns the package name of an "apt list" line
+func packageName(line string) string {
+	if i := strings.Index(line, "/"); i > 0 {
+		return line[:i]
+	}
+	return strings.Fields(line)[0]
+}
+
+// updateSystem updates the package lists, upgrades the packages and returns the upgraded ones.
+// With a changelog mode other than "off", APT changelogs are shown before upgrading and returned.
+func updateSystem(b packageBackend, distUpgrade bool, changelog string) (updateOutcome, error) {
+	var outcome updateOutcome
+	printMessage(Blue, getMessage("update_start"))
 
+	// Update the package list
+	printMessage(Blue, getMessage("update_packages"))
+	output, err := b.refresh()
+	if err != nil {
+		printMessage(Red, getMessage("update_error"))
+		return outcome, err
+	}
+	outcome.refreshOutput = output
+
+	// Checking for packages to update
+	upgradableLines, err := b.upgradable()
+	if err != nil {
+		printMessage(Red, getMessage("check_packages"))
+		return outcome, err
+	}
 	upgradableCount := len(upgradableLines)
 
 	if upgradableCount > 0 {
 		printMessage(Yellow, getMessage("packages_count", upgradableCount))
 		printMessage(Blue, getMessage("packages_list"))
-
-		// Display packages (limit to 10 for display)
-		displayCount := upgradableCount
-		/*if displayCount > 10 {
-			displayCount = 10
-		}*/
-
-		for i := 0; i < displayCount; i++ {
-			fmt.Println(upgradableLines[i])
+		for _, line := range upgradableLines {
+			printMessage("", line)
+		}
+		printSeparator()
+		if _, ok := b.(aptBackend); ok && changelog != ChangelogOff {
+			outcome.changelogs = previewChangelogs(b, upgradableLines, changelog)
 		}
-
-		/*if upgradableCount > 10 {
-			fmt.Printf("... %s %d %s\n", getMessage("and"), upgradableCount-10, getMessage("other_packages"))
-		}*/
-		fmt.Println()
 	} else {
 		printMessage(Green, getMessage("no_packages"))
-		return nil
+		return outcome, nil
 	}
 
 	// Package updates
 	printMessage(Blue, getMessage("installing_updates"))
-	if _, err := runCommand("sudo", "apt", "upgrade", "-y"); err != nil {
+	if err := b.upgrade(distUpgrade); err != nil {
 		printMessage(Red, getMessage("install_error"))
-		return err
-	}
-
-	// Simple upgrade
-	/*printMessage(Blue, getMessage("upgrade"))
-	if _, err := runCommand("sudo", "apt", "upgrade", "-y"); err != nil {
-		printMessage(Yellow, getMessage("dist_error"))
-	}*/
-
-	if distUpgrade {
-		// Distribution upgrade
-		printMessage(Blue, getMessage("dist_upgrade"))
-		if _, err := runCommand("sudo", "apt", "dist-upgrade", "-y"); err != nil {
-			printMessage(Yellow, getMessage("dist_error"))
-		}
-	} else {
-		// Simple upgrade
-		printMessage(Blue, getMessage("upgrade"))
-		if _, err := runCommand("sudo", "apt", "upgrade", "-y"); err != nil {
-			printMessage(Yellow, getMessage("dist_error"))
-		}
-	}
-
-	// Clean up obsolete packages
-	printMessage(Blue, getMessage("removing_obsolete"))
-	if _, err := runCommand("sudo", "apt", "autoremove", "-y"); err != nil {
-		printMessage(Yellow, getMessage("autoremove_error"))
+		return outcome, err
 	}
 
-	// Cache cleanup
-	printMessage(Blue, getMessage("cleaning_cache"))
-	if _, err := runCommand("sudo", "apt", "autoclean"); err != nil {
-		printMessage(Yellow, getMessage("autoclean_error"))
+	outcome.upgraded = make([]string, 0, upgradableCount)
+	for _, line := range upgradableLines {
+		outcome.upgraded = append(outcome.upgraded, b.packageName(line))
 	}
+	return outcome, nil
+}
 
+// cleanupSystem removes the obsolete packages and cleans the package cache.
+// Failures are only reported: the upgrade itself succeeded.
+func cleanupSystem(b packageBackend) {
+	b.cleanup()
 	printMessage(Green, getMessage("update_finished"))
-	return nil
 }
 
 // updateSnap updates Snap packages
@@ -317,9 +299,15 @@ func updateFlatpak() error {
 	return nil
 }
 
-// checkReboot checks if a reboot is necessary
-func checkReboot() error {
-	if _, err := os.Stat("/var/run/reboot-required"); os.IsNotExist(err) {
+// rebootRequired tells whether the system asks for a reboot
+func rebootRequired() bool {
+	return systemBackend(detectDistro(), defaultAptFrontend).rebootRequired()
+}
+
+// checkReboot offers to reboot when required, either by the system or by a staged firmware update.
+// beforeReboot runs once the user accepted; an error cancels the reboot.
+func checkReboot(required bool, beforeReboot func() error) error {
+	if !required {
 		printMessage(Green, getMessage("no_reboot"))
 		return nil
 	}
@@ -345,6 +333,9 @@ func checkReboot() error {
 	yesAnswers := strings.Split(getMessage("yes_answers"), ",")
 	for _, yes := range yesAnswers {
 		if response == strings.TrimSpace(yes) {
+			if err := beforeReboot(); err != nil {
+				return err
+			}
 			printMessage(Blue, getMessage("rebooting"))
 			return exec.Command("sudo", "reboot").Run()
 		}
@@ -361,40 +352,11 @@ func showVersion() {
 	fmt.Printf("Git commit: %s\n", gitCommit)
 	fmt.Printf("%s\n", getMessage("license"))
 	fmt.Printf("Language: %s\n", currentLang)
-}
-
-// showHelp displays help
-func showHelp() {
-	fmt.Printf("%s\n\n%s:\n",
-		getMessage("help_description"),
-		getMessage("description"))
-
-	// Treat long description with indentation
-	descLong := getMessage("help_desc_long")
-	lines := strings.Split(descLong, "\n")
-	for _, line := range lines {
-		fmt.Printf("  %s\n", line)
-	}
-	fmt.Println()
-
-	fmt.Printf("%s\n\n", getMessage("help_usage", os.Args[0]))
-
-	fmt.Printf("%s\n", getMessage("help_options"))
-	fmt.Printf("  -h, --help      %s\n", getMessage("flag_help"))
-	fmt.Printf("  -v, --version   %s\n", getMessage("flag_version"))
-	fmt.Printf("  -s, --snapshot  %s\n", getMessage("flag_snapshot"))
-	fmt.Printf("  --no-snap       %s\n", getMessage("flag_no_snap"))
-	fmt.Printf("  --no-flatpak    %s\n", getMessage("flag_no_flatpak"))
-	fmt.Printf("  --no-reboot     %s\n", getMessage("flag_no_reboot"))
-	fmt.Printf("  --dist-upgrade  %s\n", getMessage("flag_dist_upgrade"))
-
-	fmt.Printf("\n%s\n", getMessage("help_examples"))
-	fmt.Printf("  %s              %s\n", os.Args[0], getMessage("help_example_1"))
-	fmt.Printf("  %s -s           %s\n", os.Args[0], getMessage("help_example_2"))
-	fmt.Printf("  %s --no-snap --no-flatpak  %s\n", os.Args[0], getMessage("help_example_4"))
-
-	fmt.Printf("\n%s\n  NDXDev (NDXDev@gmail.com)\n", getMessage("help_author"))
-	fmt.Printf("\n%s\n  MIT\n", getMessage("help_license"))
+	distro := detectDistro()
+	fmt.Printf("Platform: %s\n", distro)
+	if warning := platformWarning(distro, time.Now()); warning != "" {
+		printMessage(Yellow, warning)
+	}
 }
 
 func main() {
@@ -408,107 +370,381 @@ func main() {
 		}
 	}
 
-	// Default configuration
-	config := Config{
-		CreateSnapshot:    false,
-		UpdateSnap:        true,
-		UpdateFlatpak:     true,
-		CheckRebootNeeded: true,
-	}
-
-	var distUpgrade bool = false
+	// Configuration file, then command-line flags
+	config, err := loadConfig(configPath())
+	os.Exit(runCLI(config, err, os.Args[1:]))
+}
 
-	// Definition of flags
-	var help, showVersionFlag bool
-	flag.BoolVar(&help, "h", false, getMessage("flag_help"))
-	flag.BoolVar(&help, "help", false, getMessage("flag_help"))
-	flag.BoolVar(&showVersionFlag, "v", false, getMessage("flag_version"))
-	flag.BoolVar(&showVersionFlag, "version", false, getMessage("flag_version"))
-	flag.BoolVar(&config.CreateSnapshot, "s", false, getMessage("flag_snapshot"))
-	flag.BoolVar(&config.CreateSnapshot, "snapshot", false, getMessage("flag_snapshot"))
+// setupUpgrade defines the upgrade command, the default one
+func setupUpgrade(fs *flag.FlagSet, config *Config) func([]string) int {
+	var showVersionFlag bool
+	fs.BoolVar(&showVersionFlag, "v", false, getMessage("flag_version"))
+	fs.BoolVar(&showVersionFlag, "version", false, getMessage("flag_version"))
+	fs.BoolVar(&config.CreateSnapshot, "s", config.CreateSnapshot, getMessage("flag_snapshot"))
+	fs.BoolVar(&config.CreateSnapshot, "snapshot", config.CreateSnapshot, getMessage("flag_snapshot"))
 
 	var noSnap, noFlatpak, noReboot bool
-	flag.BoolVar(&noSnap, "no-snap", false, getMessage("flag_no_snap"))
-	flag.BoolVar(&noFlatpak, "no-flatpak", false, getMessage("flag_no_flatpak"))
-	flag.BoolVar(&noReboot, "no-reboot", false, getMessage("flag_no_reboot"))
+	fs.BoolVar(&noSnap, "no-snap", false, getMessage("flag_no_snap"))
+	fs.BoolVar(&noFlatpak, "no-flatpak", false, getMessage("flag_no_flatpak"))
+	fs.BoolVar(&noReboot, "no-reboot", false, getMessage("flag_no_reboot"))
+	var noPlugins bool
+	fs.BoolVar(&noPlugins, "no-plugins", false, getMessage("flag_no_plugins"))
+
+	fs.BoolVar(&config.DistUpgrade, "dist-upgrade", config.DistUpgrade, getMessage("flag_dist_upgrade"))
+	fs.StringVar(&config.ReportFile, "report", config.ReportFile, getMessage("flag_report"))
+	fs.StringVar(&config.Firmware, "firmware", config.Firmware, getMessage("flag_firmware"))
+	fs.StringVar(&config.AptFrontend, "apt-frontend", config.AptFrontend, getMessage("flag_apt_frontend"))
+	fs.StringVar(&config.Changelog, "changelog", config.Changelog, getMessage("flag_changelog"))
+	fs.BoolVar(&config.Select, "select", false, getMessage("flag_select"))
+	fs.BoolVar(&config.HoldDeselected, "hold-deselected", false, getMessage("flag_hold_deselected"))
+	fs.BoolVar(&config.TUI, "tui", false, getMessage("flag_tui"))
+	fs.IntVar(&config.LockWait, "wait", config.LockWait, getMessage("flag_wait"))
+
+	return func([]string) int {
+		// Kept for backward compatibility with "uubu --version"
+		if showVersionFlag {
+			showVersion()
+			return 0
+		}
+		if !validFirmwareMode(config.Firmware) {
+			printMessage(Red, getMessage("invalid_firmware_mode", config.Firmware))
+			return ExitUsage
+		}
+		if !validAptFrontend(config.AptFrontend) {
+			printMessage(Red, getMessage("invalid_apt_frontend", config.AptFrontend, strings.Join(aptFrontendNames(), ", ")))
+			return ExitUsage
+		}
+		if !validChangelogMode(config.Changelog) {
+			printMessage(Red, getMessage("invalid_changelog_mode", config.Changelog))
+			return ExitUsage
+		}
+		if config.Select && !isTerminal(os.Stdin) {
+			printMessage(Red, getMessage("select_no_terminal"))
+			return ExitUsage
+		}
+
+		// Applying negative flags
+		if noSnap {
+			config.UpdateSnap = false
+		}
+		if noFlatpak {
+			config.UpdateFlatpak = false
+		}
+		if noReboot {
+			config.CheckRebootNeeded = false
+		}
+		if noPlugins {
+			config.PluginDir = ""
+		}
 
-	flag.BoolVar(&distUpgrade, "dist-upgrade", false, getMessage("flag_dist_upgrade"))
+		return runUpgrade(*config, nil)
+	}
+}
+
+// runUpgrade updates the system and returns the exit code.
+// journal is the journal of an interrupted run to resume, nil for a new run.
+func runUpgrade(config Config, journal *runJournal) int {
+	// Header
+	printMessage(Green, getMessage("app_title"))
+	printMessage(Blue, getMessage("start_time", time.Now().Format("2006-01-02 15:04:05")))
+	printSeparator()
 
-	flag.Parse()
+	// Preliminary checks
+	if err := checkRoot(); err != nil {
+		printMessage(Red, err.Error())
+		return ExitError
+	}
 
-	// Help and version management
-	if help {
-		showHelp()
-		os.Exit(0)
+	// One run at a time: concurrent apt calls would fail or interleave
+	lock, err := acquireLock(config.LockFile, time.Duration(config.LockWait)*time.Second)
+	if err != nil {
+		printMessage(Red, getMessage("error_lock", err))
+		return lockExitCode(err)
+	}
+	defer lock.release()
+	stopSignals := watchSignals()
+	defer stopSignals()
+
+	result := newRunResult()
+	defer startRunLog(config, result).close()
+	printMessage(Blue, getMessage("summary_platform", result.Platform))
+	if result.PlatformWarning != "" {
+		printMessage(Yellow, result.PlatformWarning)
+	}
+	printSeparator()
+	if journal != nil {
+		result.ResumedFrom = journal.RunID
+	} else {
+		path := journalPath(config)
+		if previous, _ := loadJournal(path); previous != nil {
+			printMessage(Yellow, getMessage("journal_replaced", previous.RunID))
+		}
+		journal = newJournal(path, config, result)
+		journal.save()
+
+		// Holds of the previous selections past their date
+		releaseHolds(holdsPath(config), false)
+		if config.Select {
+			if err := selectRun(config, result, config.HoldDeselected); err != nil {
+				printMessage(Yellow, getMessage("select_error", err))
+			}
+		}
 	}
 
-	if showVersionFlag {
-		showVersion()
-		os.Exit(0)
+	var dash *dashboard
+	if config.TUI {
+		dash = startRunDashboard(config, result)
+		defer dash.stop()
 	}
 
-	// Applying negative flags
-	if noSnap {
-		config.UpdateSnap = false
+	err = runSteps(config, result, journal)
+	if dash != nil {
+		dash.setReboot(result.RebootRequired)
 	}
-	if noFlatpak {
-		config.UpdateFlatpak = false
+	if errors.Is(err, errInterrupted) {
+		// Kept for "uubu resume"
+		return interruptRun(config, result, journal)
 	}
-	if noReboot {
-		config.CheckRebootNeeded = false
+	// Holds of this run only, unless kept with --hold-deselected
+	releaseHolds(holdsPath(config), false)
+	if err != nil {
+		finishRun(config, result)
+		dash.stop()
+		printMessage(Red, err.Error())
+		return runExitCode(result)
 	}
+	journal.remove()
+	// Ctrl-C at the reboot prompt quits at once
+	stopSignals()
 
-	// Header
-	printMessage(Green, getMessage("app_title"))
-	printMessage(Blue, getMessage("start_time", time.Now().Format("2006-01-02 15:04:05")))
-	fmt.Println()
+	if config.CheckRebootNeeded {
+		// Notifications and report are sent before the reboot prompt
+		finishRun(config, result)
+		dash.stop()
+		beforeReboot := func() error { return runHooks(config, result, "pre-reboot", "") }
+		if err := checkReboot(result.RebootRequired, beforeReboot); err != nil {
+			printMessage(Yellow, getMessage("error_reboot", err))
+		}
+	} else {
+		finishRun(config, result)
+		dash.stop()
+	}
 
-	// Preliminary checks
-	if err := checkRoot(); err != nil {
-		log.Fatal(err)
+	printMessage(Green, getMessage("app_finished"))
+	printMessage(Blue, getMessage("end_time", time.Now().Format("2006-01-02 15:04:05")))
+	return runExitCode(result)
+}
+
+// errInterrupted stops the steps after a SIGINT or SIGTERM
+var errInterrupted = errors.New("interrupted")
+
+// runSteps runs the steps of the run, skipping those completed before an interruption.
+// It returns an error when the run must stop: critical failure, aborting hook or signal.
+func runSteps(config Config, result *RunResult, journal *runJournal) error {
+	start := time.Now()
+	err := checkInternet()
+	result.addStep(StepInternet, start, err, true)
+	if err != nil {
+		return err
 	}
 
-	if err := checkInternet(); err != nil {
-		log.Fatal(err)
+	if err := runHooks(config, result, "pre-upgrade", ""); err != nil {
+		return err
 	}
 
 	// Creation of the snapshot if requested
-	if config.CreateSnapshot {
-		if err := createSnapshot(); err != nil {
+	if interrupted.Load() {
+		return errInterrupted
+	}
+	if journal.done(StepSnapshot) {
+		resumedStep(result, StepSnapshot)
+	} else if config.CreateSnapshot {
+		if err := runHooks(config, result, "pre-snapshot", StepSnapshot); err != nil {
+			return err
+		}
+		start = time.Now()
+		err = runStep(config, StepSnapshot, createSnapshot)
+		if result.interruptedStep(StepSnapshot, start, err) {
+			return errInterrupted
+		}
+		result.addStep(StepSnapshot, start, err, false)
+		if err != nil {
 			printMessage(Yellow, getMessage("error_snapshot", err))
+		} else {
+			result.SnapshotCreated = commandExists("timeshift")
 		}
-		fmt.Println()
+		if err := runHooks(config, result, "post-snapshot", StepSnapshot); err != nil {
+			return err
+		}
+		journal.complete(StepSnapshot)
+		printSeparator()
+	} else {
+		result.skipStep(StepSnapshot)
 	}
 
-	// System Update
-	if err := updateSystem(distUpgrade); err != nil {
-		log.Fatal(getMessage("error_update", err))
-	}
-	fmt.Println()
+	// System, Snap, Flatpak and plugin updates
+	var aptUpdate string // Output of the system refresh, for the repository check
+	for _, step := range updaterSteps(config) {
+		name := step.updater.Name()
+		if interrupted.Load() {
+			return errInterrupted
+		}
+		if journal.done(name) {
+			resumedStep(result, name)
+			continue
+		}
+		if !step.enabled {
+			result.skipStep(name)
+			continue
+		}
+		if !step.updater.Detect() {
+			// Debian, Mint, Pop!_OS... do not install snap: no warning there
+			if name != "snap" || result.Platform.shipsSnap() {
+				printMessage(Yellow, getMessage("updater_missing", name))
+				printSeparator()
+			}
+			result.skipStep(name)
+			continue
+		}
 
-	// Updating Snap packages
-	if config.UpdateSnap {
-		if err := updateSnap(); err != nil {
-			printMessage(Yellow, getMessage("error_snap", err))
+		if err := runHooks(config, result, "pre-"+name, name); err != nil {
+			return err
+		}
+		start = time.Now()
+		var outcome updateOutcome
+		err := runStep(config, name, func() (err error) {
+			outcome, err = runUpdater(step.updater)
+			return err
+		})
+		// Checked first: Ctrl-C during "apt update" must not fail the run
+		if result.interruptedStep(name, start, err) {
+			return errInterrupted
 		}
-		fmt.Println()
+		result.addStep(name, start, err, step.critical)
+		if err != nil && step.critical {
+			return errors.New(getMessage("error_update", err))
+		}
+		if err != nil {
+			printMessage(Yellow, getMessage("error_updater", name, err))
+		}
+		result.Upgraded = append(result.Upgraded, outcome.upgraded...)
+		result.Changelogs = append(result.Changelogs, outcome.changelogs...)
+		if outcome.refreshOutput != "" {
+			aptUpdate = outcome.refreshOutput
+		}
+		if err := runHooks(config, result, "post-"+name, name); err != nil {
+			return err
+		}
+		journal.complete(name)
+		printSeparator()
+	}
+
+	// Repositories, from the output of the system step
+	if interrupted.Load() {
+		return errInterrupted
+	}
+	if journal.done(StepRepositories) {
+		resumedStep(result, StepRepositories)
+	} else if config.RepoCheck && systemBackend(result.Platform, config.AptFrontend).name() == "apt" {
+		repositoriesStep(config, result, aptUpdate)
+		journal.complete(StepRepositories)
+		printSeparator()
+	} else {
+		result.skipStep(StepRepositories)
 	}
 
-	// Update Flatpak packages
-	if config.UpdateFlatpak {
-		if err := updateFlatpak(); err != nil {
-			printMessage(Yellow, getMessage("error_flatpak", err))
+	// Firmware updates
+	if interrupted.Load() {
+		return errInterrupted
+	}
+	if journal.done(StepFirmware) {
+		resumedStep(result, StepFirmware)
+	} else if config.Firmware != FirmwareOff {
+		if err := runHooks(config, result, "pre-firmware", StepFirmware); err != nil {
+			return err
+		}
+		start = time.Now()
+		var firmware []FirmwareUpdate
+		err := runStep(config, StepFirmware, func() (err error) {
+			firmware, err = updateFirmware(config.Firmware)
+			return err
+		})
+		if result.interruptedStep(StepFirmware, start, err) {
+			return errInterrupted
 		}
-		fmt.Println()
+		result.addStep(StepFirmware, start, err, false)
+		if err != nil {
+			printMessage(Yellow, getMessage("error_firmware", err))
+		}
+		result.Firmware = firmware
+		if err := runHooks(config, result, "post-firmware", StepFirmware); err != nil {
+			return err
+		}
+		journal.complete(StepFirmware)
+		printSeparator()
+	} else {
+		result.skipStep(StepFirmware)
 	}
 
 	// Reboot Check
-	if config.CheckRebootNeeded {
-		if err := checkReboot(); err != nil {
-			printMessage(Yellow, getMessage("error_reboot", err))
+	result.RebootRequired = rebootRequired()
+	if config.Firmware == FirmwareApply && firmwareNeedsReboot(result.Firmware) {
+		result.RebootRequired = true
+	}
+	if err := runHooks(config, result, "post-upgrade", ""); err != nil {
+		return err
+	}
+	if interrupted.Load() {
+		return errInterrupted
+	}
+	return nil
+}
+
+// resumedStep records a step completed before the interruption of a resumed run
+func resumedStep(result *RunResult, name string) {
+	printMessage(Green, getMessage("step_already_done", name))
+	result.skipStep(name)
+}
+
+// interruptRun ends a run stopped by a signal, keeping its journal for "uubu resume"
+func interruptRun(config Config, result *RunResult, journal *runJournal) int {
+	result.Interrupted = true
+	result.Success = false
+	result.Errors = append(result.Errors, getMessage("summary_interrupted"))
+	journal.Interrupted = true
+	journal.save()
+	finishRun(config, result)
+
+	printMessage(Yellow, getMessage("run_interrupted", programName()))
+	return ExitInterrupted
+}
+
+// finishRun closes the run summary, writes the report and sends notifications
+func finishRun(config Config, result *RunResult) {
+	result.finish()
+
+	if config.ReportFile != "" {
+		if err := writeReport(config.ReportFile, result); err != nil {
+			printMessage(Yellow, getMessage("error_report", err))
 		}
 	}
 
-	printMessage(Green, getMessage("app_finished"))
-	printMessage(Blue, getMessage("end_time", time.Now().Format("2006-01-02 15:04:05")))
+	if config.MetricsFile != "" {
+		if err := exportRunMetrics(config, result); err != nil {
+			printMessage(Yellow, getMessage("error_metrics", err))
+		}
+	}
+
+	if err := appendHistory(historyPath(config), result); err != nil {
+		printMessage(Yellow, getMessage("error_history", err))
+	}
+
+	sendNotifications(config.Notifications, result)
+	activeLog.finish(result)
+
+	if config.Email != nil && shouldNotify(config.Email.Events, result) {
+		if err := sendEmail(config.Email, result); err != nil {
+			printMessage(Yellow, getMessage("email_error", err))
+		}
+	}
 }
@@ -3,7 +3,10 @@ package main
 import (
 	"os"
 	"os/user"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -138,6 +141,36 @@ func TestCreateSnapshot_NoTimeshift(t *testing.T) {
 	}
 }
 
+func TestCreateSnapshot_Transaction(t *testing.T) {
+	if !commandExists("sh") {
+		t.Skip("sh non installé")
+	}
+	// Fake sudo and timeshift, the latter recording its process group
+	dir := t.TempDir()
+	pgid := filepath.Join(dir, "pgid")
+	scripts := map[string]string{
+		"sudo":      "#!/bin/sh\nexec \"$@\"\n",
+		"timeshift": "#!/bin/sh\ncut -d' ' -f5 /proc/$$/stat > " + pgid + "\n",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if err := createSnapshot(); err != nil {
+		t.Fatalf("createSnapshot() = %v", err)
+	}
+	data, err := os.ReadFile(pgid)
+	if err != nil {
+		t.Fatalf("timeshift non lancé: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got == strconv.Itoa(syscall.Getpgrp()) {
+		t.Errorf("timeshift dans le groupe de processus de uubu (%s), attendu son propre groupe", got)
+	}
+}
+
 func TestCheckInternet(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Test d'intégration ignoré en mode court")
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// roffEscape escapes text for a roff man page
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// manHeading turns a help heading ("OPTIONS:") into a man section name
+func manHeading(key string) string {
+	return strings.ToUpper(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(getMessage(key)), ":")))
+}
+
+// manDate returns the date of the page, honoring SOURCE_DATE_EPOCH for reproducible builds
+func manDate() string {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if sec, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC().Format("2006-01-02")
+		}
+	}
+	return time.Now().UTC().Format("2006-01-02")
+}
+
+// manFlags writes the flags of cmd as a tagged paragraph list
+func manFlags(sb *strings.Builder, cmd *command) {
+	for _, f := range completionFlags(cmd) {
+		sb.WriteString(".TP\n")
+		names := `\-\-` + roffEscape(f.long)
+		if len(f.long) == 1 {
+			names = `\-` + f.long
+		}
+		if f.short != "" {
+			names = `\-` + f.short + ", " + names
+		}
+		if f.isBool {
+			fmt.Fprintf(sb, ".B \"%s\"\n", names)
+		} else {
+			arg, ok := flagArgs[f.long]
+			if !ok {
+				arg = "VALUE"
+			}
+			fmt.Fprintf(sb, ".BI \"%s \" %s\n", names, arg)
+		}
+		sb.WriteString(roffEscape(f.usage) + "\n")
+	}
+}
+
+// manPage renders the man page in the current language
+func manPage() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, ".\\\" Generated by \"uubu __man\" from the locale files, do not edit.\n")
+	fmt.Fprintf(&sb, ".TH UUBU 8 \"%s\" \"uubu %s\"\n", manDate(), roffEscape(version))
+
+	name := getMessage("help_description")
+	if i := strings.Index(name, " - "); i >= 0 {
+		name = name[i+3:]
+	}
+	fmt.Fprintf(&sb, ".SH %s\nuubu \\- %s\n", manHeading("man_name"), roffEscape(name))
+
+	fmt.Fprintf(&sb, ".SH %s\n", manHeading("man_synopsis"))
+	sb.WriteString(".B uubu\n[\\fICOMMAND\\fR] [\\fIOPTIONS\\fR]\n")
+
+	fmt.Fprintf(&sb, ".SH %s\n", manHeading("description"))
+	sb.WriteString(roffEscape(getMessage("help_desc_long")) + "\n")
+
+	fmt.Fprintf(&sb, ".SH %s\n", manHeading("help_commands"))
+	for _, c := range visibleCommands() {
+		fmt.Fprintf(&sb, ".TP\n.B %s%s\n%s\n", c.name, roffEscape(commandArgs(c.name)), roffEscape(getMessage("cmd_"+c.name)))
+	}
+
+	fmt.Fprintf(&sb, ".SH %s\n", manHeading("help_options"))
+	manFlags(&sb, findCommand(defaultCommand))
+	for _, c := range visibleCommands() {
+		if c.name == defaultCommand {
+			continue
+		}
+		cmd := c
+		fmt.Fprintf(&sb, ".SS \"uubu %s\"\n", c.name)
+		manFlags(&sb, &cmd)
+	}
+
+	fmt.Fprintf(&sb, ".SH %s\n", manHeading("help_examples"))
+	examples := [][2]string{
+		{"uubu", "help_example_1"},
+		{"uubu \\-s", "help_example_2"},
+		{"uubu \\-\\-no\\-snap \\-\\-no\\-flatpak", "help_example_4"},
+		{"uubu check", "help_example_check"},
+	}
+	for _, e := range examples {
+		comment := strings.TrimSpace(strings.TrimPrefix(getMessage(e[1]), "#"))
+		fmt.Fprintf(&sb, ".TP\n.B %s\n%s\n", e[0], roffEscape(comment))
+	}
+
+	fmt.Fprintf(&sb, ".SH %s\n", manHeading("man_files"))
+	fmt.Fprintf(&sb, ".TP\n.I %s\n%s\n", defaultConfigPath, roffEscape(getMessage("man_file_config")))
+	fmt.Fprintf(&sb, ".TP\n.I ~/.local/state/uubu/history.jsonl\n%s\n", roffEscape(getMessage("man_file_history")))
+
+	fmt.Fprintf(&sb, ".SH %s\n", manHeading("man_exit_status"))
+	for _, e := range exitCodes {
+		fmt.Fprintf(&sb, ".TP\n.B %d\n%s\n", e.code, roffEscape(getMessage(e.key)))
+	}
+
+	fmt.Fprintf(&sb, ".SH %s\n", manHeading("man_environment"))
+	fmt.Fprintf(&sb, ".TP\n.B UUBU_LANG\n%s\n", roffEscape(getMessage("man_env_lang")))
+	fmt.Fprintf(&sb, ".TP\n.B UUBU_CONFIG\n%s\n", roffEscape(getMessage("man_env_config")))
+
+	fmt.Fprintf(&sb, ".SH %s\nNDXDev <NDXDev@gmail.com>\n", manHeading("help_author"))
+	fmt.Fprintf(&sb, ".SH %s\nMIT\n", manHeading("help_license"))
+
+	return sb.String()
+}
+
+// manLocale returns the man directory of a language ("zh-tw" -> "zh_TW")
+func manLocale(lang string) string {
+	if i := strings.Index(lang, "-"); i > 0 {
+		return lang[:i] + "_" + strings.ToUpper(lang[i+1:])
+	}
+	return lang
+}
+
+// availableLanguages lists the embedded locale files
+func availableLanguages() []string {
+	entries, err := localesFS.ReadDir("locales")
+	if err != nil {
+		return nil
+	}
+	var langs []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".json") {
+			langs = append(langs, strings.TrimSuffix(e.Name(), ".json"))
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// writeManPage writes a gzipped man page
+func writeManPage(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path) // #nosec G304 -- path built from the output directory
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	// Reproducible archives: no name nor timestamp in the header
+	if _, err := zw.Write([]byte(content)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// manTranslated tells whether the current catalog translates the man page
+// instead of repeating the English text
+func manTranslated(english map[string]string) bool {
+	for key, text := range english {
+		if strings.HasPrefix(key, "man_") && messages[key] != text {
+			return true
+		}
+	}
+	return false
+}
+
+// generateManPages writes man8/uubu.8.gz (English) and <lang>/man8/uubu.8.gz under dir.
+// Languages without a translation of the man page are left to the English one.
+func generateManPages(dir string) error {
+	previous := currentLang
+	defer func() { _ = loadLanguage(previous) }()
+
+	if err := loadLanguage("en"); err != nil {
+		return err
+	}
+	english := messages
+
+	for _, lang := range availableLanguages() {
+		if err := loadLanguage(lang); err != nil {
+			return err
+		}
+		if lang != "en" && !manTranslated(english) {
+			continue
+		}
+		path := filepath.Join(dir, manLocale(lang), "man8", "uubu.8.gz")
+		if lang == "en" {
+			path = filepath.Join(dir, "man8", "uubu.8.gz")
+		}
+		if err := writeManPage(path, manPage()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupMan defines the hidden command generating the man pages at build time
+func setupMan(fs *flag.FlagSet, config *Config) func([]string) int {
+	return func(args []string) int {
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "usage: uubu __man DIR")
+			return ExitUsage
+		}
+		if err := generateManPages(args[0]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return ExitError
+		}
+		return 0
+	}
+}
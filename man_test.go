@@ -0,0 +1,94 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoffEscape(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"no-snap", `no\-snap`},
+		{`C:\path`, `C:\epath`},
+		{".hidden\n'quote", "\\&.hidden\n\\&'quote"},
+		{"plain text", "plain text"},
+	}
+
+	for _, tt := range tests {
+		if got := roffEscape(tt.input); got != tt.expected {
+			t.Errorf("roffEscape(%q) = %q, attendu %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestManLocale(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fr", "fr"},
+		{"zh-tw", "zh_TW"},
+		{"pt-br", "pt_BR"},
+	}
+
+	for _, tt := range tests {
+		if got := manLocale(tt.input); got != tt.expected {
+			t.Errorf("manLocale(%q) = %q, attendu %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestManPage_Content(t *testing.T) {
+	page := manPage()
+	for _, want := range []string{".TH UUBU 8", ".SH ", `\-\-no\-snap`, `.SS "uubu check"`, "UUBU_CONFIG"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("La page de manuel devrait contenir %q", want)
+		}
+	}
+	if strings.Contains(page, ".B __complete") || strings.Contains(page, ".B __man") {
+		t.Error("La page de manuel ne devrait pas documenter les commandes cachées")
+	}
+}
+
+func TestGenerateManPages(t *testing.T) {
+	dir := t.TempDir()
+	previous := currentLang
+	if err := generateManPages(dir); err != nil {
+		t.Fatalf("generateManPages: %v", err)
+	}
+	if currentLang != previous {
+		t.Errorf("La langue devrait être restaurée: %q, attendu %q", currentLang, previous)
+	}
+
+	// Untranslated languages fall back to the English page
+	for _, path := range []string{"it/man8/uubu.8.gz", "zh_TW/man8/uubu.8.gz"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); !os.IsNotExist(err) {
+			t.Errorf("Page %s présente, attendu absente (non traduite)", path)
+		}
+	}
+
+	for _, path := range []string{"man8/uubu.8.gz", "fr/man8/uubu.8.gz", "de/man8/uubu.8.gz", "es/man8/uubu.8.gz"} {
+		f, err := os.Open(filepath.Join(dir, path))
+		if err != nil {
+			t.Errorf("Page %s absente: %v", path, err)
+			continue
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			t.Errorf("Page %s non compressée: %v", path, err)
+			continue
+		}
+		data, _ := io.ReadAll(zr)
+		f.Close()
+		if strings.Contains(string(data), "[MISSING") {
+			t.Errorf("La page %s contient des messages manquants", path)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default file read by the node_exporter textfile collector
+const defaultMetricsPath = "/var/lib/prometheus/node-exporter/uubu.prom"
+
+// metric is one metric family of the textfile
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	samples []sample
+}
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+func gauge(name, help string, value float64) metric {
+	return metric{name: name, help: help, kind: "gauge", samples: []sample{{value: value}}}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// pendingMetrics describes the pending updates and the system state
+func pendingMetrics(p PendingUpdates, snapshots int) []metric {
+	pending := metric{
+		name: "uubu_pending_updates",
+		help: "Number of pending updates per source.",
+		kind: "gauge",
+		samples: []sample{
+			{labels: fmt.Sprintf(`source=%q`, p.backendName()), value: float64(p.APT)},
+		},
+	}
+	if p.SnapOK {
+		pending.samples = append(pending.samples, sample{labels: `source="snap"`, value: float64(p.Snap)})
+	}
+	if p.FlatpakOK {
+		pending.samples = append(pending.samples, sample{labels: `source="flatpak"`, value: float64(p.Flatpak)})
+	}
+	plugins := make([]string, 0, len(p.Plugins))
+	for name := range p.Plugins {
+		plugins = append(plugins, name)
+	}
+	sort.Strings(plugins)
+	for _, name := range plugins {
+		pending.samples = append(pending.samples, sample{labels: fmt.Sprintf(`source=%q`, name), value: float64(p.Plugins[name])})
+	}
+
+	metrics := []metric{
+		pending,
+		gauge("uubu_pending_security_updates", "Number of pending security updates of the system package manager.", float64(p.APTSecurity)),
+		gauge("uubu_reboot_required", "Whether the system requires a reboot.", boolValue(rebootRequired())),
+		gauge("uubu_last_check_timestamp_seconds", "Time of the last pending updates check.", float64(time.Now().Unix())),
+	}
+	if snapshots >= 0 {
+		metrics = append(metrics, gauge("uubu_snapshots", "Number of Timeshift snapshots.", float64(snapshots)))
+	}
+	return metrics
+}
+
+// runMetrics describes the outcome of a run
+func runMetrics(r *RunResult) []metric {
+	durations := metric{
+		name: "uubu_step_duration_seconds",
+		help: "Duration of each step of the last run.",
+		kind: "gauge",
+	}
+	for _, s := range r.Steps {
+		if s.Status == StatusSkipped {
+			continue
+		}
+		durations.samples = append(durations.samples, sample{
+			labels: fmt.Sprintf(`step=%q,status=%q`, s.Name, s.Status),
+			value:  s.Duration.Seconds(),
+		})
+	}
+
+	return []metric{
+		gauge("uubu_last_run_timestamp_seconds", "Time of the end of the last run.", float64(r.EndTime.Unix())),
+		gauge("uubu_last_run_success", "Whether the last run succeeded.", boolValue(r.Success)),
+		gauge("uubu_last_run_upgraded_packages", "Number of packages upgraded by the last run.", float64(len(r.Upgraded))),
+		durations,
+	}
+}
+
+// formatMetrics renders metrics in the Prometheus text format
+func formatMetrics(metrics []metric) string {
+	var sb strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&sb, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(&sb, "# TYPE %s %s\n", m.name, m.kind)
+		for _, s := range m.samples {
+			if s.labels != "" {
+				fmt.Fprintf(&sb, "%s{%s} %g\n", m.name, s.labels, s.value)
+			} else {
+				fmt.Fprintf(&sb, "%s %g\n", m.name, s.value)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// metricName returns the metric family of a textfile line
+func metricName(line string) string {
+	if strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE ") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 {
+			return fields[2]
+		}
+		return ""
+	}
+	if i := strings.IndexAny(line, "{ "); i > 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// mergeMetrics keeps the families of previous that are not replaced by fresh,
+// so that a check does not erase the outcome of the last run
+func mergeMetrics(previous string, fresh []metric) string {
+	replaced := make(map[string]bool)
+	for _, m := range fresh {
+		replaced[m.name] = true
+	}
+
+	kept := make(map[string][]string)
+	var order []string
+	for _, line := range strings.Split(previous, "\n") {
+		name := metricName(line)
+		if name == "" || replaced[name] {
+			continue
+		}
+		if _, ok := kept[name]; !ok {
+			order = append(order, name)
+		}
+		kept[name] = append(kept[name], line)
+	}
+	sort.Strings(order)
+
+	var sb strings.Builder
+	for _, name := range order {
+		sb.WriteString(strings.Join(kept[name], "\n"))
+		sb.WriteString("\n")
+	}
+	sb.WriteString(formatMetrics(fresh))
+	return sb.String()
+}
+
+// writeMetrics updates the textfile with fresh metrics.
+// The file is replaced atomically so the collector never reads a partial file.
+func writeMetrics(path string, fresh []metric) error {
+	previous, err := os.ReadFile(path) // #nosec G304 -- path chosen by the administrator
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".uubu-*.prom")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(mergeMetrics(string(previous), fresh)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// metricsPath returns the textfile to write
+func metricsPath(config Config) string {
+	if config.MetricsFile != "" {
+		return config.MetricsFile
+	}
+	return defaultMetricsPath
+}
+
+// exportRunMetrics writes the run outcome and the remaining pending updates
+func exportRunMetrics(config Config, r *RunResult) error {
+	metrics := runMetrics(r)
+	if pending, err := countPending(config); err == nil {
+		metrics = append(metrics, pendingMetrics(pending, countSnapshots())...)
+	}
+	return writeMetrics(metricsPath(config), metrics)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatMetrics(t *testing.T) {
+	out := formatMetrics(pendingMetrics(PendingUpdates{APT: 5, APTSecurity: 2, Snap: 1, SnapOK: true}, 3))
+
+	for _, want := range []string{
+		"# TYPE uubu_pending_updates gauge\n",
+		`uubu_pending_updates{source="apt"} 5` + "\n",
+		`uubu_pending_updates{source="snap"} 1` + "\n",
+		"uubu_pending_security_updates 2\n",
+		"uubu_snapshots 3\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Les métriques devraient contenir %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `source="flatpak"`) {
+		t.Error("Flatpak indisponible ne devrait pas être exporté")
+	}
+}
+
+func TestRunMetrics(t *testing.T) {
+	r := newRunResult()
+	r.addStep("apt", time.Now(), nil, true)
+	r.addStep("snap", time.Now(), errors.New("timeout"), false)
+	r.skipStep("flatpak")
+	r.finish()
+
+	out := formatMetrics(runMetrics(r))
+	if !strings.Contains(out, "uubu_last_run_success 1\n") {
+		t.Errorf("Succès attendu:\n%s", out)
+	}
+	if !strings.Contains(out, `step="snap",status="warning"`) {
+		t.Errorf("Durée de l'étape snap attendue:\n%s", out)
+	}
+	if strings.Contains(out, `step="flatpak"`) {
+		t.Error("Une étape ignorée ne devrait pas avoir de durée")
+	}
+}
+
+func TestWriteMetrics_KeepsRunMetrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uubu.prom")
+
+	r := newRunResult()
+	r.finish()
+	if err := writeMetrics(path, runMetrics(r)); err != nil {
+		t.Fatalf("writeMetrics() a retourné une erreur: %v", err)
+	}
+	if err := writeMetrics(path, pendingMetrics(PendingUpdates{APT: 4}, -1)); err != nil {
+		t.Fatalf("writeMetrics() a retourné une erreur: %v", err)
+	}
+	if err := writeMetrics(path, pendingMetrics(PendingUpdates{APT: 1}, -1)); err != nil {
+		t.Fatalf("writeMetrics() a retourné une erreur: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	if !strings.Contains(out, "uubu_last_run_success 1") {
+		t.Errorf("Les métriques de la dernière exécution devraient être conservées:\n%s", out)
+	}
+	if strings.Count(out, "# TYPE uubu_pending_updates") != 1 || !strings.Contains(out, `uubu_pending_updates{source="apt"} 1`) {
+		t.Errorf("Les métriques en attente devraient être remplacées:\n%s", out)
+	}
+}
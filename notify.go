@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Notification events
+const (
+	EventAlways  = "always"
+	EventFailure = "failure"
+	EventReboot  = "reboot"
+)
+
+// NotifierConfig describes a notification backend in the configuration file
+type NotifierConfig struct {
+	// webhook, slack, ntfy or matrix
+	Type string `json:"type"`
+	// Webhook URL, ntfy topic URL or Matrix homeserver URL
+	URL string `json:"url"`
+	// always, failure, reboot (default: always)
+	Events []string `json:"events"`
+	// Access token (ntfy, matrix)
+	Token string `json:"token,omitempty"`
+	// Matrix room ID
+	Room string `json:"room,omitempty"`
+	// Extra HTTP headers (webhook)
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Notifier sends the run summary to an external service
+type Notifier interface {
+	Notify(r *RunResult) error
+}
+
+// Timeout for notification requests
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// newNotifier creates the backend described by c
+func newNotifier(c NotifierConfig) (Notifier, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("missing url")
+	}
+	for _, e := range c.Events {
+		if e != EventAlways && e != EventFailure && e != EventReboot {
+			return nil, fmt.Errorf("unknown event %q", e)
+		}
+	}
+
+	switch c.Type {
+	case "webhook":
+		return &webhookNotifier{url: c.URL, headers: c.Headers}, nil
+	case "slack":
+		return &slackNotifier{url: c.URL}, nil
+	case "ntfy":
+		return &ntfyNotifier{url: c.URL, token: c.Token}, nil
+	case "matrix":
+		if c.Room == "" || c.Token == "" {
+			return nil, fmt.Errorf("matrix requires room and token")
+		}
+		return &matrixNotifier{homeserver: strings.TrimRight(c.URL, "/"), room: c.Room, token: c.Token}, nil
+	}
+	return nil, fmt.Errorf("unknown notifier type %q", c.Type)
+}
+
+// shouldNotify tells whether the run matches one of the configured events
+func shouldNotify(events []string, r *RunResult) bool {
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		switch e {
+		case EventAlways:
+			return true
+		case EventFailure:
+			if !r.Success || len(r.Errors) > 0 {
+				return true
+			}
+		case EventReboot:
+			if r.RebootRequired {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sendNotifications notifies every configured backend interested in the run
+func sendNotifications(configs []NotifierConfig, r *RunResult) {
+	for _, c := range configs {
+		if !shouldNotify(c.Events, r) {
+			continue
+		}
+		n, err := newNotifier(c)
+		if err == nil {
+			err = n.Notify(r)
+		}
+		if err != nil {
+			printMessage(Yellow, getMessage("notify_error", c.Type, err))
+		}
+	}
+}
+
+// postRequest sends a request and checks the response status
+func postRequest(req *http.Request) error {
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return nil
+}
+
+// postJSON sends v as a JSON body
+func postJSON(method, target string, v interface{}, headers map[string]string) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return postRequest(req)
+}
+
+// webhookNotifier posts the full run result as JSON
+type webhookNotifier struct {
+	url     string
+	headers map[string]string
+}
+
+func (n *webhookNotifier) Notify(r *RunResult) error {
+	payload := struct {
+		*RunResult
+		Summary string `json:"summary"`
+	}{r, r.Summary()}
+	return postJSON(http.MethodPost, n.url, payload, n.headers)
+}
+
+// slackNotifier posts the summary to a Slack-compatible incoming webhook
+type slackNotifier struct {
+	url string
+}
+
+func (n *slackNotifier) Notify(r *RunResult) error {
+	return postJSON(http.MethodPost, n.url, map[string]string{"text": r.Summary()}, nil)
+}
+
+// ntfyNotifier publishes the summary to an ntfy topic
+type ntfyNotifier struct {
+	url   string
+	token string
+}
+
+func (n *ntfyNotifier) Notify(r *RunResult) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, strings.NewReader(r.Summary()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", "uubu - "+r.Hostname)
+	if !r.Success {
+		req.Header.Set("Priority", "high")
+		req.Header.Set("Tags", "warning")
+	} else if r.RebootRequired {
+		req.Header.Set("Tags", "arrows_counterclockwise")
+	} else {
+		req.Header.Set("Tags", "white_check_mark")
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	return postRequest(req)
+}
+
+// matrixNotifier sends the summary as a message in a Matrix room
+type matrixNotifier struct {
+	homeserver string
+	room       string
+	token      string
+}
+
+func (n *matrixNotifier) Notify(r *RunResult) error {
+	txnID := fmt.Sprintf("uubu-%s-%d", r.ID, time.Now().UnixNano())
+	target := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		n.homeserver, url.PathEscape(n.room), url.PathEscape(txnID))
+	message := map[string]string{
+		"msgtype": "m.text",
+		"body":    r.Summary(),
+	}
+	return postJSON(http.MethodPut, target, message, map[string]string{"Authorization": "Bearer " + n.token})
+}
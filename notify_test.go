@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captured stores the last request received by the stand-in server
+type captured struct {
+	method  string
+	path    string
+	headers http.Header
+	body    string
+}
+
+func newStandIn(t *testing.T, status int) (*httptest.Server, *captured) {
+	t.Helper()
+	c := &captured{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		c.method = r.Method
+		c.path = r.URL.EscapedPath()
+		c.headers = r.Header
+		c.body = string(data)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, c
+}
+
+func sampleResult() *RunResult {
+	r := newRunResult()
+	r.Hostname = "host1"
+	r.Upgraded = []string{"firefox", "libreoffice-core"}
+	r.finish()
+	return r
+}
+
+func TestShouldNotify(t *testing.T) {
+	ok := sampleResult()
+	failed := sampleResult()
+	failed.addStep("apt", time.Now(), errors.New("boom"), true)
+	reboot := sampleResult()
+	reboot.RebootRequired = true
+
+	testCases := []struct {
+		name     string
+		events   []string
+		result   *RunResult
+		expected bool
+	}{
+		{"default always", nil, ok, true},
+		{"always", []string{EventAlways}, ok, true},
+		{"failure on success", []string{EventFailure}, ok, false},
+		{"failure on failure", []string{EventFailure}, failed, true},
+		{"reboot without reboot", []string{EventReboot}, ok, false},
+		{"reboot with reboot", []string{EventReboot}, reboot, true},
+		{"failure or reboot", []string{EventFailure, EventReboot}, reboot, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := shouldNotify(tc.events, tc.result); got != tc.expected {
+				t.Errorf("shouldNotify(%v) = %v, attendu %v", tc.events, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestNewNotifier_Invalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config NotifierConfig
+	}{
+		{"missing url", NotifierConfig{Type: "webhook"}},
+		{"unknown type", NotifierConfig{Type: "irc", URL: "http://localhost"}},
+		{"unknown event", NotifierConfig{Type: "slack", URL: "http://localhost", Events: []string{"sometimes"}}},
+		{"matrix without room", NotifierConfig{Type: "matrix", URL: "http://localhost", Token: "t"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := newNotifier(tc.config); err == nil {
+				t.Errorf("newNotifier(%+v) devrait retourner une erreur", tc.config)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	srv, c := newStandIn(t, http.StatusOK)
+
+	n, err := newNotifier(NotifierConfig{Type: "webhook", URL: srv.URL, Headers: map[string]string{"X-Token": "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(sampleResult()); err != nil {
+		t.Fatalf("Notify() a retourné une erreur: %v", err)
+	}
+
+	if c.method != http.MethodPost || c.headers.Get("X-Token") != "secret" {
+		t.Errorf("Requête inattendue: %s %v", c.method, c.headers)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(c.body), &payload); err != nil {
+		t.Fatalf("Corps JSON invalide: %v", err)
+	}
+	if payload["hostname"] != "host1" || payload["summary"] == "" {
+		t.Errorf("Payload inattendu: %v", payload)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	srv, c := newStandIn(t, http.StatusOK)
+
+	n, _ := newNotifier(NotifierConfig{Type: "slack", URL: srv.URL})
+	if err := n.Notify(sampleResult()); err != nil {
+		t.Fatalf("Notify() a retourné une erreur: %v", err)
+	}
+
+	var payload map[string]string
+	if err := json.Unmarshal([]byte(c.body), &payload); err != nil {
+		t.Fatalf("Corps JSON invalide: %v", err)
+	}
+	if payload["text"] == "" {
+		t.Error("Le champ text devrait contenir le résumé")
+	}
+}
+
+func TestNtfyNotifier(t *testing.T) {
+	srv, c := newStandIn(t, http.StatusOK)
+
+	n, _ := newNotifier(NotifierConfig{Type: "ntfy", URL: srv.URL + "/updates", Token: "tk"})
+	r := sampleResult()
+	r.addStep("apt", time.Now(), errors.New("boom"), true)
+	if err := n.Notify(r); err != nil {
+		t.Fatalf("Notify() a retourné une erreur: %v", err)
+	}
+
+	if c.path != "/updates" {
+		t.Errorf("Topic inattendu: %s", c.path)
+	}
+	if c.headers.Get("Priority") != "high" || c.headers.Get("Authorization") != "Bearer tk" {
+		t.Errorf("En-têtes inattendus: %v", c.headers)
+	}
+	if !strings.Contains(c.body, "apt: boom") {
+		t.Errorf("Le résumé devrait contenir l'erreur: %q", c.body)
+	}
+}
+
+func TestMatrixNotifier(t *testing.T) {
+	srv, c := newStandIn(t, http.StatusOK)
+
+	n, _ := newNotifier(NotifierConfig{Type: "matrix", URL: srv.URL + "/", Room: "!abc:example.org", Token: "tk"})
+	if err := n.Notify(sampleResult()); err != nil {
+		t.Fatalf("Notify() a retourné une erreur: %v", err)
+	}
+
+	if c.method != http.MethodPut {
+		t.Errorf("Méthode inattendue: %s", c.method)
+	}
+	if !strings.HasPrefix(c.path, "/_matrix/client/v3/rooms/%21abc:example.org/send/m.room.message/") {
+		t.Errorf("Chemin inattendu: %s", c.path)
+	}
+	if c.headers.Get("Authorization") != "Bearer tk" {
+		t.Errorf("Jeton manquant: %v", c.headers)
+	}
+}
+
+func TestNotifier_HTTPError(t *testing.T) {
+	srv, _ := newStandIn(t, http.StatusInternalServerError)
+
+	n, _ := newNotifier(NotifierConfig{Type: "slack", URL: srv.URL})
+	if err := n.Notify(sampleResult()); err == nil {
+		t.Error("Notify() devrait retourner une erreur sur un statut HTTP 500")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Color modes of --color
+const (
+	ColorAuto   = "auto"   // colors on a terminal, unless NO_COLOR is set
+	ColorAlways = "always" // colors even in pipes and log files
+	ColorNever  = "never"  // plain text
+)
+
+// outputLevel is the amount of output of uubu
+type outputLevel int
+
+// Output levels, from --quiet to --debug
+const (
+	// Only warnings and errors
+	LevelQuiet outputLevel = iota
+	LevelNormal
+	// Also the output of the commands
+	LevelVerbose
+	// Also every command run, with its exit status and duration
+	LevelDebug
+)
+
+// Output settings, applied from the flags before a command runs
+var (
+	colorEnabled = true
+	verbosity    = LevelNormal
+)
+
+// validColorMode tells whether mode is a known color mode
+func validColorMode(mode string) bool {
+	switch mode {
+	case ColorAuto, ColorAlways, ColorNever:
+		return true
+	}
+	return false
+}
+
+// useColor tells whether the output on f is colored in mode.
+// In auto mode NO_COLOR (https://no-color.org) and TERM=dumb disable colors.
+func useColor(mode string, f *os.File) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(f)
+}
+
+// colorize wraps s in color when colors are enabled
+func colorize(color, s string) string {
+	if !colorEnabled || color == "" {
+		return s
+	}
+	return color + s + NC
+}
+
+// outputFlags are the output flags shared by every command
+type outputFlags struct {
+	color                 *string
+	quiet, verbose, debug *bool
+}
+
+// addOutputFlags defines the output flags on fs, the color defaulting to the configuration
+func addOutputFlags(fs *flag.FlagSet, config *Config) outputFlags {
+	o := outputFlags{
+		color:   fs.String("color", config.Color, getMessage("flag_color")),
+		quiet:   new(bool),
+		verbose: fs.Bool("verbose", false, getMessage("flag_verbose")),
+		debug:   fs.Bool("debug", false, getMessage("flag_debug")),
+	}
+	fs.BoolVar(o.quiet, "q", false, getMessage("flag_quiet"))
+	fs.BoolVar(o.quiet, "quiet", false, getMessage("flag_quiet"))
+	return o
+}
+
+// apply sets the colors and the output level, the most verbose flag winning
+func (o outputFlags) apply() error {
+	if !validColorMode(*o.color) {
+		return errors.New(getMessage("invalid_color_mode", *o.color))
+	}
+	colorEnabled = useColor(*o.color, os.Stdout)
+	switch {
+	case *o.debug:
+		verbosity = LevelDebug
+	case *o.verbose:
+		verbosity = LevelVerbose
+	case *o.quiet:
+		verbosity = LevelQuiet
+	default:
+		verbosity = LevelNormal
+	}
+	return nil
+}
+
+// printSeparator prints the blank line between two steps, nothing in quiet mode
+func printSeparator() {
+	if verbosity > LevelQuiet {
+		fmt.Println()
+	}
+}
+
+// printDebug prints a debug line on the standard error with --debug
+func printDebug(format string, args ...interface{}) {
+	if verbosity >= LevelDebug {
+		fmt.Fprintln(os.Stderr, colorize(Blue, "[debug] "+fmt.Sprintf(format, args...)))
+	}
+}
+
+// debugCommand prints a command about to run with --debug
+func debugCommand(name string, args []string) {
+	printDebug("$ %s", strings.Join(append([]string{name}, args...), " "))
+}
+
+// debugResult prints the outcome of a command with --debug
+func debugResult(name string, err error, elapsed time.Duration) {
+	code := 0
+	if err != nil {
+		code = exitCode(err)
+	}
+	printDebug("%s: exit %d (%s)", name, code, elapsed.Round(time.Millisecond))
+}
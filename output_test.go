@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUseColor(t *testing.T) {
+	// A regular file is not a terminal
+	f, err := os.Create(filepath.Join(t.TempDir(), "output"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		mode    string
+		noColor string
+		term    string
+		want    bool
+	}{
+		{ColorAlways, "1", "dumb", true},
+		{ColorNever, "", "xterm", false},
+		{ColorAuto, "", "xterm", false},
+		{ColorAuto, "1", "xterm", false},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("NO_COLOR", tt.noColor)
+		t.Setenv("TERM", tt.term)
+		if got := useColor(tt.mode, f); got != tt.want {
+			t.Errorf("useColor(%q) avec NO_COLOR=%q TERM=%q = %v, attendu %v", tt.mode, tt.noColor, tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestColorize(t *testing.T) {
+	defer func() { colorEnabled = true }()
+
+	colorEnabled = true
+	if got := colorize(Red, "erreur"); got != Red+"erreur"+NC {
+		t.Errorf("colorize() = %q, attendu en rouge", got)
+	}
+	if got := colorize("", "texte"); got != "texte" {
+		t.Errorf("colorize() sans couleur = %q, attendu %q", got, "texte")
+	}
+	colorEnabled = false
+	if got := colorize(Red, "erreur"); got != "erreur" {
+		t.Errorf("colorize() désactivé = %q, attendu %q", got, "erreur")
+	}
+}
+
+func TestOutputFlags(t *testing.T) {
+	defer func() { colorEnabled, verbosity = true, LevelNormal }()
+
+	tests := []struct {
+		args    []string
+		level   outputLevel
+		color   bool
+		invalid bool
+	}{
+		{nil, LevelNormal, false, false},
+		{[]string{"-q"}, LevelQuiet, false, false},
+		{[]string{"--quiet", "--color=always"}, LevelQuiet, true, false},
+		{[]string{"--verbose"}, LevelVerbose, false, false},
+		{[]string{"--quiet", "--debug"}, LevelDebug, false, false},
+		{[]string{"--color=sometimes"}, LevelNormal, false, true},
+	}
+
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		o := addOutputFlags(fs, &Config{Color: ColorNever})
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatalf("Parse(%v): %v", tt.args, err)
+		}
+		colorEnabled, verbosity = true, LevelNormal
+		err := o.apply()
+		if (err != nil) != tt.invalid {
+			t.Errorf("apply() pour %v: erreur %v, attendu invalide=%v", tt.args, err, tt.invalid)
+			continue
+		}
+		if !tt.invalid && (verbosity != tt.level || colorEnabled != tt.color) {
+			t.Errorf("apply() pour %v: niveau %d couleurs %v, attendu %d %v", tt.args, verbosity, colorEnabled, tt.level, tt.color)
+		}
+	}
+}
+
+func TestPrintMessageQuiet(t *testing.T) {
+	defer func() { verbosity = LevelNormal }()
+	verbosity = LevelQuiet
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	printMessage(Green, "succès")
+	printMessage("", "paquet")
+	printMessage(Yellow, "avertissement")
+	printSeparator()
+	os.Stdout = stdout
+	w.Close()
+
+	output, _ := io.ReadAll(r)
+	if got, want := string(output), colorize(Yellow, "avertissement")+"\n"; got != want {
+		t.Errorf("Sortie en mode silencieux = %q, attendu %q", got, want)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PendingUpdates counts the updates waiting to be installed
+type PendingUpdates struct {
+	// Package manager of the distribution counted in APT and APTSecurity: apt, dnf or pacman
+	Backend     string `json:"backend"`
+	APT         int    `json:"apt"`
+	APTSecurity int    `json:"apt_security"`
+	Snap        int    `json:"snap"`
+	Flatpak     int    `json:"flatpak"`
+	SnapOK      bool   `json:"-"`
+	FlatpakOK   bool   `json:"-"`
+	// Pending updates of the plugins, by plugin name
+	Plugins map[string]int `json:"plugins,omitempty"`
+}
+
+// Total returns the number of pending updates of every source
+func (p PendingUpdates) Total() int {
+	total := p.APT + p.Snap + p.Flatpak
+	for _, n := range p.Plugins {
+		total += n
+	}
+	return total
+}
+
+// backendName returns the package manager counted, apt when unknown
+func (p PendingUpdates) backendName() string {
+	if p.Backend == "" {
+		return "apt"
+	}
+	return p.Backend
+}
+
+// isSecurityUpdate tells whether an "apt list" line comes from a security pocket
+func isSecurityUpdate(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	i := strings.Index(fields[0], "/")
+	if i < 0 {
+		return false
+	}
+	for _, suite := range strings.Split(fields[0][i+1:], ",") {
+		if strings.HasSuffix(suite, "-security") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSnapRefreshList returns the snaps listed by "snap refresh --list"
+func parseSnapRefreshList(output string) []string {
+	var names []string
+	for i, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		line = strings.TrimSpace(line)
+		// Header "Name Version Rev ..." or "All snaps up to date."
+		if i == 0 || line == "" {
+			continue
+		}
+		names = append(names, strings.Fields(line)[0])
+	}
+	return names
+}
+
+// parseFlatpakUpdates returns the refs listed by "flatpak remote-ls --updates"
+func parseFlatpakUpdates(output string) []string {
+	var refs []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			refs = append(refs, line)
+		}
+	}
+	return refs
+}
+
+// countPending counts the pending system, Snap, Flatpak and plugin updates
+// without touching the system
+func countPending(config Config) (PendingUpdates, error) {
+	backend := systemBackend(detectDistro(), config.AptFrontend)
+	pending := PendingUpdates{Backend: backend.name()}
+
+	lines, err := backend.upgradable()
+	if err != nil {
+		return pending, err
+	}
+	pending.APT = len(lines)
+	if pending.APTSecurity, err = backend.countSecurity(lines); err != nil {
+		return pending, err
+	}
+
+	snap := snapUpdater{}
+	if config.UpdateSnap && snap.Detect() {
+		if names, err := snap.Pending(); err == nil {
+			pending.Snap = len(names)
+			pending.SnapOK = true
+		}
+	}
+
+	flatpak := flatpakUpdater{}
+	if config.UpdateFlatpak && flatpak.Detect() {
+		if refs, err := flatpak.Pending(); err == nil {
+			pending.Flatpak = len(refs)
+			pending.FlatpakOK = true
+		}
+	}
+
+	for _, p := range discoverPlugins(config.PluginDir) {
+		if !p.Detect() {
+			continue
+		}
+		if names, err := p.Pending(); err == nil {
+			if pending.Plugins == nil {
+				pending.Plugins = make(map[string]int)
+			}
+			pending.Plugins[p.Name()] = len(names)
+		}
+	}
+
+	return pending, nil
+}
+
+// Lines of "timeshift --list" describing a snapshot: "0    >  2025-07-16_10-00-01  O  ..."
+var timeshiftSnapshotLine = regexp.MustCompile(`^\d+\s+>\s+\S+`)
+
+// countSnapshots returns the number of Timeshift snapshots, or -1 if unknown
+func countSnapshots() int {
+	if !commandExists("timeshift") {
+		return -1
+	}
+	// -n: never wait for a password in unattended runs
+	output, err := runCommand("sudo", "-n", "timeshift", "--list", "--scripted")
+	if err != nil {
+		return -1
+	}
+	count := 0
+	for _, line := range strings.Split(output, "\n") {
+		if timeshiftSnapshotLine.MatchString(strings.TrimSpace(line)) {
+			count++
+		}
+	}
+	return count
+}
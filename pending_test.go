@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestIsSecurityUpdate(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected bool
+	}{
+		{"firefox/noble-updates,noble-security 130.0.1 amd64 [upgradable from: 129.0.2]", true},
+		{"libreoffice-core/noble-updates 1:24.2.5 amd64 [upgradable from: 1:24.2.4]", false},
+		{"openssl/bookworm-security 3.0.15-1~deb12u1 amd64 [upgradable from: 3.0.14-1~deb12u2]", true},
+		{"", false},
+		{"nosuite", false},
+	}
+
+	for _, tc := range testCases {
+		if got := isSecurityUpdate(tc.line); got != tc.expected {
+			t.Errorf("isSecurityUpdate(%q) = %v, attendu %v", tc.line, got, tc.expected)
+		}
+	}
+}
+
+func TestParseSnapRefreshList(t *testing.T) {
+	testCases := []struct {
+		name     string
+		output   string
+		expected int
+	}{
+		{"up to date", "All snaps up to date.\n", 0},
+		{"two snaps", `Name     Version  Rev   Size   Publisher   Notes
+firefox  131.0    4955  283MB  mozilla✓    -
+core22   20240904 1621  77MB   canonical✓  base
+`, 2},
+		{"empty", "", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseSnapRefreshList(tc.output); len(got) != tc.expected {
+				t.Errorf("parseSnapRefreshList() = %v, attendu %d snaps", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestParseFlatpakUpdates(t *testing.T) {
+	output := "org.mozilla.firefox\norg.gimp.GIMP\n\n"
+	if got := parseFlatpakUpdates(output); len(got) != 2 || got[1] != "org.gimp.GIMP" {
+		t.Errorf("parseFlatpakUpdates() = %v, attendu 2 applications", got)
+	}
+	if got := parseFlatpakUpdates(""); len(got) != 0 {
+		t.Errorf("parseFlatpakUpdates(\"\") = %v, attendu aucune", got)
+	}
+}
+
+func TestPendingTotal(t *testing.T) {
+	p := PendingUpdates{APT: 3, APTSecurity: 1, Snap: 2, Flatpak: 1}
+	if p.Total() != 6 {
+		t.Errorf("Total() = %d, attendu 6", p.Total())
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Default directory of the external updaters
+const defaultPluginDir = "/etc/uubu/plugins.d"
+
+// Version of the JSON protocol spoken with the plugins
+const pluginProtocol = 1
+
+// Actions sent to a plugin, one per Updater method
+const (
+	pluginDetect  = "detect"
+	pluginPending = "pending"
+	pluginUpdate  = "update"
+	pluginCleanup = "cleanup"
+)
+
+// pluginRequest is written on the standard input of the plugin
+type pluginRequest struct {
+	Protocol int    `json:"protocol"`
+	Action   string `json:"action"`
+	Lang     string `json:"lang"`
+}
+
+// pluginResponse is read on the standard output of the plugin
+type pluginResponse struct {
+	// detect: the package manager is installed
+	Installed bool `json:"installed"`
+	// pending: updates waiting, update: upgraded packages
+	Packages []string `json:"packages"`
+	// Failure of the action, also reported by a non-zero exit status
+	Error string `json:"error"`
+}
+
+// pluginUpdater is an Updater implemented by an external executable
+type pluginUpdater struct {
+	name string
+	path string
+}
+
+// pluginName derives the name of the step from the file name ("pipx.sh" -> "pipx")
+func pluginName(file string) string {
+	if ext := filepath.Ext(file); ext != "" && ext != file {
+		file = strings.TrimSuffix(file, ext)
+	}
+	return file
+}
+
+// safeExecutable tells whether a file may be run: a regular executable file
+// that neither the group nor the others can modify
+func safeExecutable(info os.FileInfo) bool {
+	mode := info.Mode()
+	return mode.IsRegular() && mode&0o111 != 0 && mode&0o022 == 0
+}
+
+// usablePlugin tells whether a directory entry may be run as a plugin:
+// a safe executable that is not a package leftover
+func usablePlugin(info os.FileInfo) bool {
+	name := info.Name()
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") || strings.Contains(name, ".dpkg-") {
+		return false
+	}
+	return safeExecutable(info)
+}
+
+// discoverPlugins returns the plugins of dir in lexical order.
+// Plugins named like a built-in step are ignored.
+func discoverPlugins(dir string) []Updater {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	builtin := builtinStepNames()
+	seen := make(map[string]bool)
+	var plugins []Updater
+	for _, e := range entries {
+		info, err := os.Stat(filepath.Join(dir, e.Name()))
+		if err != nil || !usablePlugin(info) {
+			continue
+		}
+		name := pluginName(e.Name())
+		if builtin[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		plugins = append(plugins, &pluginUpdater{name: name, path: filepath.Join(dir, e.Name())})
+	}
+	return plugins
+}
+
+// call runs the plugin for one action, with the timeout and the retries of its step.
+// The progress written by the plugin on its standard error is shown to the user
+// during the update and the cleanup only: "uubu check" prints a single status line.
+func (p *pluginUpdater) call(action string) (pluginResponse, error) {
+	var resp pluginResponse
+	req, err := json.Marshal(pluginRequest{Protocol: pluginProtocol, Action: action, Lang: currentLang})
+	if err != nil {
+		return resp, err
+	}
+
+	var stdout bytes.Buffer
+	_, runErr := runWithPolicy(commandIO{
+		stdin:  req,
+		stdout: &stdout,
+		env:    []string{"UUBU_PLUGIN_ACTION=" + action},
+		show:   action == pluginUpdate || action == pluginCleanup,
+	}, p.path)
+
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err := json.Unmarshal(out, &resp); err != nil {
+			return resp, fmt.Errorf("%s %s: invalid response: %v", p.name, action, err)
+		}
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("%s %s: %s", p.name, action, resp.Error)
+	}
+	if runErr != nil {
+		return resp, fmt.Errorf("%s %s: %v", p.name, action, runErr)
+	}
+	return resp, nil
+}
+
+func (p *pluginUpdater) Name() string { return p.name }
+
+func (p *pluginUpdater) Detect() bool {
+	resp, err := p.call(pluginDetect)
+	return err == nil && resp.Installed
+}
+
+func (p *pluginUpdater) Pending() ([]string, error) {
+	resp, err := p.call(pluginPending)
+	return resp.Packages, err
+}
+
+func (p *pluginUpdater) Update() ([]string, error) {
+	printMessage(Blue, getMessage("updating_plugin", p.name))
+	resp, err := p.call(pluginUpdate)
+	if err != nil {
+		return nil, err
+	}
+	printMessage(Green, getMessage("plugin_updated", p.name, len(resp.Packages)))
+	return resp.Packages, nil
+}
+
+func (p *pluginUpdater) Cleanup() error {
+	_, err := p.call(pluginCleanup)
+	return err
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writePlugin installs a shell plugin answering from its action
+func writePlugin(t *testing.T, dir, file, script string, mode os.FileMode) {
+	t.Helper()
+	content := "#!/bin/sh\nrequest=$(cat)\ncase \"$UUBU_PLUGIN_ACTION\" in\n" + script + "\nesac\n"
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, file), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPluginName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"pipx", "pipx"},
+		{"cargo.sh", "cargo"},
+		{"rustup.py", "rustup"},
+	}
+
+	for _, tt := range tests {
+		if got := pluginName(tt.input); got != tt.expected {
+			t.Errorf("pluginName(%q) = %q, attendu %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestDiscoverPlugins(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "pipx", "", 0o755)
+	writePlugin(t, dir, "brew.sh", "", 0o755)
+	writePlugin(t, dir, "notexec", "", 0o644)
+	writePlugin(t, dir, "writable", "", 0o777)
+	// Named like built-in steps
+	for _, name := range []string{"apt", "dnf", "pacman", "snapshot", "internet", "repositories"} {
+		writePlugin(t, dir, name, "", 0o755)
+	}
+	writePlugin(t, dir, "npm.dpkg-old", "", 0o755)
+	if err := os.Mkdir(filepath.Join(dir, "subdir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, p := range discoverPlugins(dir) {
+		names = append(names, p.Name())
+	}
+	if got := strings.Join(names, ","); got != "brew,pipx" {
+		t.Errorf("discoverPlugins() = %q, attendu %q", got, "brew,pipx")
+	}
+
+	if plugins := discoverPlugins(""); plugins != nil {
+		t.Errorf("Aucun plugin ne devrait être chargé sans répertoire: %v", plugins)
+	}
+	if plugins := discoverPlugins(filepath.Join(dir, "absent")); plugins != nil {
+		t.Errorf("Aucun plugin ne devrait être chargé d'un répertoire absent: %v", plugins)
+	}
+}
+
+func TestPluginUpdater_Protocol(t *testing.T) {
+	if !commandExists("sh") {
+		t.Skip("sh non installé")
+	}
+	dir := t.TempDir()
+	writePlugin(t, dir, "pipx", `detect) echo '{"installed": true}' ;;
+pending) echo '{"packages": ["black", "httpie"]}' ;;
+update) echo "mise à jour" >&2; echo '{"packages": ["black"]}' ;;
+cleanup) echo '{"error": "cache verrouillé"}'; exit 1 ;;`, 0o755)
+
+	plugins := discoverPlugins(dir)
+	if len(plugins) != 1 {
+		t.Fatalf("%d plugins, attendu 1", len(plugins))
+	}
+	p := plugins[0]
+
+	if !p.Detect() {
+		t.Error("Detect() devrait retourner true")
+	}
+	pending, err := p.Pending()
+	if err != nil || len(pending) != 2 {
+		t.Errorf("Pending() = %v, %v, attendu 2 paquets", pending, err)
+	}
+
+	outcome, err := runUpdater(p)
+	if err != nil {
+		t.Errorf("runUpdater() ne devrait pas échouer sur le nettoyage: %v", err)
+	}
+	if len(outcome.upgraded) != 1 || outcome.upgraded[0] != "black" {
+		t.Errorf("runUpdater() = %v, attendu [black]", outcome.upgraded)
+	}
+
+	err = p.Cleanup()
+	if err == nil || !strings.Contains(err.Error(), "cache verrouillé") {
+		t.Errorf("Cleanup() devrait retourner l'erreur du plugin, obtenu %v", err)
+	}
+}
+
+func TestPluginUpdater_CheckOutput(t *testing.T) {
+	if !commandExists("sh") {
+		t.Skip("sh non installé")
+	}
+	dir := t.TempDir()
+	writePlugin(t, dir, "pipx", `pending) echo "lecture de l'index" >&2; echo '{"packages": ["black"]}' ;;
+update) echo "mise à jour" >&2; echo '{"packages": ["black"]}' ;;`, 0o755)
+	p := discoverPlugins(dir)[0]
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	_, pendingErr := p.Pending()
+	_, updateErr := p.Update()
+	os.Stdout = stdout
+	w.Close()
+	if pendingErr != nil || updateErr != nil {
+		t.Fatalf("Pending() = %v, Update() = %v", pendingErr, updateErr)
+	}
+
+	// "uubu check" prints a single status line: only the update is shown
+	output, _ := io.ReadAll(r)
+	if got := string(output); strings.Contains(got, "lecture de l'index") || !strings.Contains(got, "mise à jour\n") {
+		t.Errorf("Sortie du plugin = %q, attendu la seule progression de la mise à jour", got)
+	}
+}
+
+func TestPluginUpdater_InvalidResponse(t *testing.T) {
+	if !commandExists("sh") {
+		t.Skip("sh non installé")
+	}
+	dir := t.TempDir()
+	writePlugin(t, dir, "broken", `*) echo 'not json' ;;`, 0o755)
+
+	p := discoverPlugins(dir)[0]
+	if p.Detect() {
+		t.Error("Detect() devrait retourner false sur une réponse invalide")
+	}
+	if _, err := p.Update(); err == nil {
+		t.Error("Update() devrait retourner une erreur sur une réponse invalide")
+	}
+}
+
+func TestPluginUpdater_Timeout(t *testing.T) {
+	if !commandExists("sh") || !commandExists("sleep") {
+		t.Skip("sh ou sleep non installé")
+	}
+	dir := t.TempDir()
+	writePlugin(t, dir, "slow", `update) exec sleep 10 ;;`, 0o755)
+	config := defaultConfig()
+	config.StepTimeouts = map[string]int{"slow": 1}
+
+	p := discoverPlugins(dir)[0]
+	start := time.Now()
+	err := runStep(config, "slow", func() error {
+		_, err := p.Update()
+		return err
+	})
+	if classifyError("slow", err) != KindTimeout {
+		t.Errorf("runStep() = %v, attendu un délai dépassé", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("Le plugin aurait dû être arrêté au bout du délai")
+	}
+}
+
+func TestUpdaterSteps(t *testing.T) {
+	config := defaultConfig()
+	config.UpdateFlatpak = false
+	config.PluginDir = ""
+
+	steps := updaterSteps(config)
+	expected := []struct {
+		name     string
+		enabled  bool
+		critical bool
+	}{
+		{"apt", true, true},
+		{"snap", true, false},
+		{"flatpak", false, false},
+	}
+	if len(steps) != len(expected) {
+		t.Fatalf("%d étapes, attendu %d", len(steps), len(expected))
+	}
+	for i, e := range expected {
+		s := steps[i]
+		if s.updater.Name() != e.name || s.enabled != e.enabled || s.critical != e.critical {
+			t.Errorf("Étape %d = %s/%v/%v, attendu %s/%v/%v",
+				i, s.updater.Name(), s.enabled, s.critical, e.name, e.enabled, e.critical)
+		}
+	}
+}
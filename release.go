@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Policy of Ubuntu release upgrades, read by do-release-upgrade
+var releaseUpgradesFile = "/etc/update-manager/release-upgrades"
+
+// Release upgrade prompts of the update-manager policy
+const (
+	PromptLTS    = "lts"
+	PromptNormal = "normal"
+	PromptNever  = "never"
+)
+
+// ReleaseUpgrade describes the release upgrade of a run
+type ReleaseUpgrade struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// parseReleasePrompt returns the Prompt setting of the release-upgrades file, "lts" by default
+func parseReleasePrompt(content string) string {
+	prompt := PromptLTS
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok && strings.TrimSpace(key) == "Prompt" {
+			prompt = strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+	return prompt
+}
+
+// releasePrompt reads the release upgrade policy of the system
+func releasePrompt() string {
+	data, err := os.ReadFile(releaseUpgradesFile) // #nosec G304 -- fixed system path
+	if err != nil {
+		return PromptLTS
+	}
+	return parseReleasePrompt(string(data))
+}
+
+// "New release '24.04.1 LTS' available." (do-release-upgrade),
+// "22.04 -> 24.04 is available" (pop-upgrade)
+var (
+	newReleaseLine    = regexp.MustCompile(`New release '([^']+)' available`)
+	popNewReleaseLine = regexp.MustCompile(`(?m)->\s*(\S+)`)
+)
+
+// parseNewRelease returns the release offered by a check, empty when there is none
+func parseNewRelease(output string) string {
+	if m := newReleaseLine.FindStringSubmatch(output); m != nil {
+		return m[1]
+	}
+	if strings.Contains(output, "is available") || strings.Contains(output, "available to upgrade") {
+		if m := popNewReleaseLine.FindStringSubmatch(output); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// releaseTool returns the release upgrade program of the distribution, empty when there is none
+func releaseTool(d Distro) string {
+	switch {
+	case d.ID == "pop" && commandExists("pop-upgrade"):
+		return "pop-upgrade"
+	case d.is("ubuntu") && commandExists("do-release-upgrade"):
+		return "do-release-upgrade"
+	}
+	return ""
+}
+
+// checkNewRelease asks the release upgrade tool for a new release, honoring the prompt policy
+func checkNewRelease(tool string) (string, error) {
+	var output string
+	var err error
+	if tool == "pop-upgrade" {
+		output, err = runCommand("pop-upgrade", "release", "check")
+	} else {
+		// Exit status 1: no new release
+		output, err = runCommand("do-release-upgrade", "-c")
+		if exitCode(err) == 1 {
+			err = nil
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %s", tool, err, strings.TrimSpace(output))
+	}
+	return parseNewRelease(output), nil
+}
+
+// releaseUpgradeCommand returns the command upgrading to the new release
+func releaseUpgradeCommand(tool string, interactive bool) []string {
+	if tool == "pop-upgrade" {
+		return []string{"sudo", "pop-upgrade", "release", "upgrade"}
+	}
+	if interactive {
+		return []string{"sudo", "do-release-upgrade"}
+	}
+	// Keeps the modified configuration files and answers the questions with their defaults
+	return []string{"sudo", "do-release-upgrade", "-f", "DistUpgradeViewNonInteractive"}
+}
+
+// runReleaseUpgrade runs the upgrade with its output on the terminal.
+// In non-interactive mode it runs in its own process group, out of reach of Ctrl-C.
+// It is never retried: a second attempt would start from a half-upgraded system.
+func runReleaseUpgrade(args []string, interactive bool) error {
+	cmd := exec.Command(args[0], args[1:]...) // #nosec G204 -- fixed commands
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if interactive {
+		cmd.Stdin = os.Stdin
+	} else {
+		sudoCredentials()
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
+	return cmd.Run()
+}
+
+// systemUpToDate tells why the system is not ready for a release upgrade, nil when it is
+func systemUpToDate(config Config) error {
+	backend := systemBackend(detectDistro(), config.AptFrontend)
+	if _, err := backend.refresh(); err != nil {
+		return err
+	}
+	lines, err := backend.upgradable()
+	if err != nil {
+		return err
+	}
+	if len(lines) > 0 {
+		return errors.New(getMessage("release_pending", len(lines), programName()))
+	}
+	if backend.rebootRequired() {
+		return errors.New(getMessage("release_reboot_first"))
+	}
+	return nil
+}
+
+// setupReleaseUpgrade defines the release-upgrade command
+func setupReleaseUpgrade(fs *flag.FlagSet, config *Config) func([]string) int {
+	checkOnly := fs.Bool("check", false, getMessage("flag_release_check"))
+	noSnapshot := fs.Bool("no-snapshot", false, getMessage("flag_no_snapshot"))
+	interactive := fs.Bool("interactive", false, getMessage("flag_interactive"))
+	fs.StringVar(&config.ReportFile, "report", config.ReportFile, getMessage("flag_report"))
+
+	return func([]string) int {
+		distro := detectDistro()
+		tool := releaseTool(distro)
+		if tool == "" {
+			printMessage(Red, getMessage("release_unsupported", distro.PrettyName))
+			return ExitError
+		}
+		prompt := releasePrompt()
+		if prompt == PromptNever && tool == "do-release-upgrade" {
+			printMessage(Yellow, getMessage("release_disabled", releaseUpgradesFile))
+			return ExitError
+		}
+
+		printMessage(Blue, getMessage("release_checking", distro, prompt))
+		release, err := checkNewRelease(tool)
+		if err != nil {
+			printMessage(Red, err.Error())
+			return kindExitCode(classifyError("release-check", err))
+		}
+		if release == "" {
+			printMessage(Green, getMessage("release_none"))
+			if *checkOnly {
+				// Same convention as "do-release-upgrade -c"
+				return 1
+			}
+			return ExitOK
+		}
+		printMessage(Yellow, getMessage("release_available", release))
+		if *checkOnly {
+			return ExitOK
+		}
+
+		if err := checkRoot(); err != nil {
+			printMessage(Red, err.Error())
+			return ExitError
+		}
+		if !*noSnapshot && !commandExists("timeshift") {
+			printMessage(Red, getMessage("release_snapshot_required"))
+			return ExitSnapshot
+		}
+		lock, err := acquireLock(config.LockFile, time.Duration(config.LockWait)*time.Second)
+		if err != nil {
+			printMessage(Red, getMessage("error_lock", err))
+			return lockExitCode(err)
+		}
+		defer lock.release()
+		// The upgrade goes on after Ctrl-C: stay alive to record it
+		stopSignals := watchSignals()
+		defer stopSignals()
+
+		result := newRunResult()
+		defer startRunLog(*config, result).close()
+		result.Release = &ReleaseUpgrade{From: distro.String(), To: release, Prompt: prompt}
+		if err := releaseSteps(*config, result, tool, *noSnapshot, *interactive); err != nil {
+			finishRun(*config, result)
+			printMessage(Red, err.Error())
+			return runExitCode(result)
+		}
+
+		// A new kernel and new libraries always need a reboot
+		result.RebootRequired = true
+		finishRun(*config, result)
+		printMessage(Green, getMessage("release_done", release))
+		if config.CheckRebootNeeded {
+			if err := checkReboot(true, func() error { return runHooks(*config, result, "pre-reboot", "") }); err != nil {
+				printMessage(Yellow, getMessage("error_reboot", err))
+			}
+		}
+		return runExitCode(result)
+	}
+}
+
+// releaseSteps checks that the system is up to date, creates the snapshot and upgrades.
+// Every step is critical: the upgrade only starts from a known good state.
+func releaseSteps(config Config, result *RunResult, tool string, noSnapshot, interactive bool) error {
+	start := time.Now()
+	printMessage(Blue, getMessage("release_verifying"))
+	err := runStep(config, StepUpToDate, func() error { return systemUpToDate(config) })
+	result.addStep(StepUpToDate, start, err, true)
+	if err != nil {
+		return err
+	}
+
+	if noSnapshot {
+		result.skipStep(StepSnapshot)
+	} else {
+		if err := runHooks(config, result, "pre-snapshot", StepSnapshot); err != nil {
+			return err
+		}
+		start = time.Now()
+		err = runStep(config, StepSnapshot, createSnapshot)
+		result.addStep(StepSnapshot, start, err, true)
+		if err != nil {
+			return errors.New(getMessage("error_snapshot", err))
+		}
+		result.SnapshotCreated = true
+		if err := runHooks(config, result, "post-snapshot", StepSnapshot); err != nil {
+			return err
+		}
+	}
+
+	if err := runHooks(config, result, "pre-release-upgrade", StepReleaseUpgrade); err != nil {
+		return err
+	}
+	printMessage(Blue, getMessage("release_upgrading", result.Release.To))
+	start = time.Now()
+	err = runReleaseUpgrade(releaseUpgradeCommand(tool, interactive), interactive)
+	result.addStep(StepReleaseUpgrade, start, err, true)
+	if err != nil {
+		return err
+	}
+	return runHooks(config, result, "post-release-upgrade", StepReleaseUpgrade)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseReleasePrompt(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"[DEFAULT]\n# Default behavior for the release upgrader.\nPrompt=lts\n", PromptLTS},
+		{"[DEFAULT]\nPrompt=normal\n", PromptNormal},
+		{"[DEFAULT]\nPrompt = never\n", PromptNever},
+		{"", PromptLTS},
+	}
+
+	for _, tt := range tests {
+		if got := parseReleasePrompt(tt.content); got != tt.expected {
+			t.Errorf("parseReleasePrompt(%q) = %q, attendu %q", tt.content, got, tt.expected)
+		}
+	}
+}
+
+func TestReleasePrompt_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "release-upgrades")
+	if err := os.WriteFile(path, []byte("[DEFAULT]\nPrompt=normal\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	previous := releaseUpgradesFile
+	defer func() { releaseUpgradesFile = previous }()
+
+	releaseUpgradesFile = path
+	if got := releasePrompt(); got != PromptNormal {
+		t.Errorf("releasePrompt() = %q, attendu %q", got, PromptNormal)
+	}
+	releaseUpgradesFile = filepath.Join(t.TempDir(), "missing")
+	if got := releasePrompt(); got != PromptLTS {
+		t.Errorf("Fichier absent: releasePrompt() = %q, attendu %q", got, PromptLTS)
+	}
+}
+
+func TestParseNewRelease(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected string
+	}{
+		{"Checking for a new Ubuntu release\nNew release '24.04.1 LTS' available.\nRun 'do-release-upgrade' to upgrade to it.\n", "24.04.1 LTS"},
+		{"Checking for a new Ubuntu release\nThere is no development version of an LTS available.\n", ""},
+		{"Checking for a new Ubuntu release\nNo new release found.\n", ""},
+		{"Release 22.04 -> 24.04 is available\n", "24.04"},
+	}
+
+	for _, tt := range tests {
+		if got := parseNewRelease(tt.output); got != tt.expected {
+			t.Errorf("parseNewRelease(%q) = %q, attendu %q", tt.output, got, tt.expected)
+		}
+	}
+}
+
+func TestReleaseUpgradeCommand(t *testing.T) {
+	cmd := releaseUpgradeCommand("do-release-upgrade", false)
+	if !strings.Contains(strings.Join(cmd, " "), "DistUpgradeViewNonInteractive") {
+		t.Errorf("Le mode non interactif devrait utiliser DistUpgradeViewNonInteractive: %v", cmd)
+	}
+	if !isTransaction(cmd[0], cmd[1:]) {
+		t.Errorf("%v devrait être une transaction", cmd)
+	}
+	if cmd := releaseUpgradeCommand("do-release-upgrade", true); len(cmd) != 2 {
+		t.Errorf("Le mode interactif ne devrait pas choisir d'interface: %v", cmd)
+	}
+}
+
+func TestSummary_Release(t *testing.T) {
+	if err := loadLanguage("en"); err != nil {
+		t.Fatal(err)
+	}
+	r := newRunResult()
+	r.Release = &ReleaseUpgrade{From: "Ubuntu 22.04.4 LTS", To: "24.04.1 LTS"}
+	r.finish()
+	if s := r.Summary(); !strings.Contains(s, "Ubuntu 22.04.4 LTS → 24.04.1 LTS") {
+		t.Errorf("Le résumé devrait mentionner la mise à niveau de version:\n%s", s)
+	}
+}
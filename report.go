@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Step status values
+const (
+	StatusOK      = "ok"
+	StatusWarning = "warning"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+	// Stopped by SIGINT or SIGTERM, resumed by "uubu resume"
+	StatusInterrupted = "interrupted"
+)
+
+// StepResult describes the outcome of one step of a run
+type StepResult struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Duration time.Duration `json:"duration_ns"`
+	Error    string        `json:"error,omitempty"`
+	// Kind of the error: network, lock, package, snapshot, hook or timeout
+	Kind string `json:"error_kind,omitempty"`
+}
+
+// RunResult is the summary of a run, shared by the report and the notifiers
+type RunResult struct {
+	ID              string       `json:"id"`
+	Hostname        string       `json:"hostname"`
+	Version         string       `json:"version"`
+	StartTime       time.Time    `json:"start_time"`
+	EndTime         time.Time    `json:"end_time"`
+	Success         bool         `json:"success"`
+	Steps           []StepResult `json:"steps"`
+	Upgraded        []string     `json:"upgraded_packages"`
+	Errors          []string     `json:"errors"`
+	SnapshotCreated bool         `json:"snapshot_created"`
+	RebootRequired  bool         `json:"reboot_required"`
+	// Stopped by SIGINT or SIGTERM, resumable with "uubu resume"
+	Interrupted bool `json:"interrupted,omitempty"`
+	// ID of the interrupted run continued by this one
+	ResumedFrom string `json:"resumed_from,omitempty"`
+	// Firmware updates found, or applied in mode "apply"
+	Firmware []FirmwareUpdate `json:"firmware_updates,omitempty"`
+	// Updates held back by the interactive selection, as "source:package"
+	Deselected []string `json:"deselected,omitempty"`
+	// Changelog entries of the upgraded APT packages, with --changelog
+	Changelogs []PackageChangelog `json:"changelogs,omitempty"`
+	// Problems of the APT repositories found after the system step
+	Repositories []repoIssue `json:"repository_issues,omitempty"`
+	// Distribution of the host, with the warning when unsupported or end-of-life
+	Platform        Distro `json:"platform"`
+	PlatformWarning string `json:"platform_warning,omitempty"`
+	// Set by the release-upgrade command
+	Release *ReleaseUpgrade `json:"release_upgrade,omitempty"`
+}
+
+// newRunResult starts the summary of a new run
+func newRunResult() *RunResult {
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	distro := detectDistro()
+	return &RunResult{
+		ID:              now.Format("20060102-150405"),
+		Hostname:        hostname,
+		Version:         version,
+		StartTime:       now,
+		Success:         true,
+		Steps:           []StepResult{},
+		Upgraded:        []string{},
+		Errors:          []string{},
+		Platform:        distro,
+		PlatformWarning: platformWarning(distro, now),
+	}
+}
+
+// addStep records the outcome of a step started at start.
+// An error on a non-critical step is recorded as a warning.
+func (r *RunResult) addStep(name string, start time.Time, err error, critical bool) {
+	step := StepResult{
+		Name:     name,
+		Status:   StatusOK,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		step.Error = err.Error()
+		step.Kind = classifyError(name, err)
+		step.Status = StatusWarning
+		if critical {
+			step.Status = StatusFailed
+			r.Success = false
+		}
+		r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", name, err))
+	}
+	r.Steps = append(r.Steps, step)
+	activeLog.stepDone(step)
+	if d := activeDashboard; d != nil {
+		d.stepDone(step)
+	}
+}
+
+// interruptedStep records a step that failed because a signal stopped its
+// command, and tells whether it did. Such a step is neither failed nor a
+// warning: the run stops and "uubu resume" runs it again.
+func (r *RunResult) interruptedStep(name string, start time.Time, err error) bool {
+	if err == nil || !interrupted.Load() {
+		return false
+	}
+	step := StepResult{Name: name, Status: StatusInterrupted, Duration: time.Since(start)}
+	r.Steps = append(r.Steps, step)
+	activeLog.stepDone(step)
+	if d := activeDashboard; d != nil {
+		d.stepDone(step)
+	}
+	return true
+}
+
+// skipStep records a step disabled by the configuration
+func (r *RunResult) skipStep(name string) {
+	step := StepResult{Name: name, Status: StatusSkipped}
+	r.Steps = append(r.Steps, step)
+	activeLog.stepDone(step)
+	if d := activeDashboard; d != nil {
+		d.stepDone(step)
+	}
+}
+
+// finish marks the end of the run
+func (r *RunResult) finish() {
+	r.EndTime = time.Now()
+}
+
+// Summary returns a short human readable summary of the run
+func (r *RunResult) Summary() string {
+	var sb strings.Builder
+
+	status := getMessage("summary_success")
+	if !r.Success {
+		status = getMessage("summary_failure")
+	}
+	sb.WriteString(getMessage("summary_title", r.Hostname, status))
+	sb.WriteString("\n")
+	if r.Platform.PrettyName != "" {
+		sb.WriteString(getMessage("summary_platform", r.Platform))
+		sb.WriteString("\n")
+	}
+	if r.PlatformWarning != "" {
+		sb.WriteString(r.PlatformWarning)
+		sb.WriteString("\n")
+	}
+	if r.Release != nil {
+		sb.WriteString(getMessage("summary_release", r.Release.From, r.Release.To))
+		sb.WriteString("\n")
+	}
+	sb.WriteString(getMessage("summary_upgraded", len(r.Upgraded)))
+	sb.WriteString("\n")
+	if r.SnapshotCreated {
+		sb.WriteString(getMessage("summary_snapshot"))
+		sb.WriteString("\n")
+	}
+	if r.RebootRequired {
+		sb.WriteString(getMessage("summary_reboot"))
+		sb.WriteString("\n")
+	}
+	if len(r.Errors) > 0 {
+		sb.WriteString(getMessage("summary_errors", len(r.Errors)))
+		sb.WriteString("\n")
+		for _, e := range r.Errors {
+			sb.WriteString("  - " + e + "\n")
+		}
+	}
+	sb.WriteString(getMessage("summary_duration", r.EndTime.Sub(r.StartTime).Round(time.Second)))
+
+	return sb.String()
+}
+
+// writeReport writes the run summary as JSON
+func writeReport(path string, r *RunResult) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
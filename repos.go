@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APT sources and legacy keyring
+var (
+	aptSourcesList = "/etc/apt/sources.list"
+	aptSourcesDir  = "/etc/apt/sources.list.d"
+	aptTrustedGPG  = "/etc/apt/trusted.gpg"
+)
+
+// Kinds of repository problems
+const (
+	RepoUnreachable = "unreachable"
+	RepoNoRelease   = "no-release"
+	RepoMissingKey  = "missing-key"
+	RepoExpiredKey  = "expired-key"
+	RepoLegacyKey   = "legacy-key"
+)
+
+// aptSource is an entry of the APT sources, one-line or deb822
+type aptSource struct {
+	File string
+	// First line of the entry or of the deb822 stanza, from 1
+	Line    int
+	URIs    []string
+	Suites  []string
+	Enabled bool
+	Deb822  bool
+	// Keyring of the signed-by option, empty when the key is in the global keyrings
+	SignedBy string
+	// Line of the "Enabled:" field of a deb822 stanza, 0 when missing
+	enabledLine int
+}
+
+// repoIssue is a problem of a repository found in the output of "apt update"
+type repoIssue struct {
+	Kind   string `json:"kind"`
+	URI    string `json:"uri"`
+	Suite  string `json:"suite,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	// Expiry date of a signing key
+	Expires string `json:"expires,omitempty"`
+}
+
+// parseSourcesList reads the entries of a one-line style sources file
+func parseSourcesList(path, content string) []aptSource {
+	var sources []aptSource
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		enabled := true
+		if strings.HasPrefix(line, "#") {
+			// Commented out entries are kept to be reported as disabled
+			line = strings.TrimSpace(strings.TrimLeft(line, "#"))
+			enabled = false
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 || (fields[0] != "deb" && fields[0] != "deb-src") {
+			continue
+		}
+		fields = fields[1:]
+		// Options: [arch=amd64 signed-by=...]
+		signedBy := ""
+		if strings.HasPrefix(fields[0], "[") {
+			for len(fields) > 0 {
+				option := strings.Trim(fields[0], "[]")
+				if value, ok := strings.CutPrefix(option, "signed-by="); ok {
+					signedBy = value
+				}
+				last := strings.HasSuffix(fields[0], "]")
+				fields = fields[1:]
+				if last {
+					break
+				}
+			}
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		sources = append(sources, aptSource{
+			File:     path,
+			Line:     i + 1,
+			URIs:     []string{fields[0]},
+			Suites:   []string{fields[1]},
+			Enabled:  enabled,
+			SignedBy: signedBy,
+		})
+	}
+	return sources
+}
+
+// parseDeb822Sources reads the stanzas of a deb822 style .sources file
+func parseDeb822Sources(path, content string) []aptSource {
+	var sources []aptSource
+	var current *aptSource
+	flush := func() {
+		if current != nil && len(current.URIs) > 0 {
+			sources = append(sources, *current)
+		}
+		current = nil
+	}
+
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			flush()
+			continue
+		}
+		if strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if current == nil {
+			current = &aptSource{File: path, Line: i + 1, Enabled: true, Deb822: true}
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "uris":
+			current.URIs = strings.Fields(value)
+		case "suites":
+			current.Suites = strings.Fields(value)
+		case "signed-by":
+			// The key may also be inlined on the following lines
+			if value = strings.TrimSpace(value); strings.HasPrefix(value, "/") {
+				current.SignedBy = value
+			}
+		case "enabled":
+			current.Enabled = strings.TrimSpace(strings.ToLower(value)) != "no"
+			current.enabledLine = i + 1
+		}
+	}
+	flush()
+	return sources
+}
+
+// loadSources reads sources.list and the files of sources.list.d
+func loadSources() []aptSource {
+	var sources []aptSource
+	if data, err := os.ReadFile(aptSourcesList); err == nil {
+		sources = append(sources, parseSourcesList(aptSourcesList, string(data))...)
+	}
+	entries, _ := os.ReadDir(aptSourcesDir)
+	for _, e := range entries {
+		path := filepath.Join(aptSourcesDir, e.Name())
+		data, err := os.ReadFile(path) // #nosec G304 -- files of the APT configuration
+		if err != nil {
+			continue
+		}
+		switch filepath.Ext(e.Name()) {
+		case ".list":
+			sources = append(sources, parseSourcesList(path, string(data))...)
+		case ".sources":
+			sources = append(sources, parseDeb822Sources(path, string(data))...)
+		}
+	}
+	return sources
+}
+
+// Messages of "apt update" about a repository
+var (
+	// E: The repository 'https://ppa.launchpadcontent.net/x/y/ubuntu noble Release' does not have a Release file.
+	noReleaseLine = regexp.MustCompile(`The repository '(\S+) (\S+) Release' does not have a Release file`)
+	// W: GPG error: https://repo.example stable InRelease: ... NO_PUBKEY 1234ABCD
+	gpgErrorLine = regexp.MustCompile(`GPG error: (\S+) (\S+) (?:In)?Release: .*?\b(NO_PUBKEY|EXPKEYSIG) ([0-9A-F]+)`)
+	// W: Failed to fetch http://host/debian/dists/stable/InRelease  Could not resolve 'host'
+	failedFetchLine = regexp.MustCompile(`Failed to fetch (\S+?)/dists/([^/\s]+)/\S*\s+(.*)`)
+	// W: https://repo.example/dists/stable/InRelease: Key is stored in legacy trusted.gpg keyring
+	legacyKeyLine = regexp.MustCompile(`(\S+?)/dists/([^/\s]+)/\S*: Key is stored in legacy trusted\.gpg keyring`)
+)
+
+// parseAptUpdateIssues extracts the repository problems of the output of "apt update"
+func parseAptUpdateIssues(output string) []repoIssue {
+	var issues []repoIssue
+	seen := make(map[string]bool)
+	add := func(i repoIssue) {
+		i.URI = strings.TrimSuffix(i.URI, "/")
+		key := i.Kind + " " + i.URI + " " + i.Suite
+		if !seen[key] {
+			seen[key] = true
+			issues = append(issues, i)
+		}
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if m := noReleaseLine.FindStringSubmatch(line); m != nil {
+			add(repoIssue{Kind: RepoNoRelease, URI: m[1], Suite: m[2]})
+		} else if m := gpgErrorLine.FindStringSubmatch(line); m != nil {
+			kind := RepoMissingKey
+			if m[3] == "EXPKEYSIG" {
+				kind = RepoExpiredKey
+			}
+			add(repoIssue{Kind: kind, URI: m[1], Suite: m[2], Detail: m[4]})
+		} else if m := legacyKeyLine.FindStringSubmatch(line); m != nil {
+			add(repoIssue{Kind: RepoLegacyKey, URI: m[1], Suite: m[2]})
+		} else if m := failedFetchLine.FindStringSubmatch(line); m != nil {
+			detail := strings.TrimSpace(m[3])
+			// A missing Release file is reported on its own line
+			if strings.Contains(detail, "404") {
+				continue
+			}
+			add(repoIssue{Kind: RepoUnreachable, URI: m[1], Suite: m[2], Detail: detail})
+		}
+	}
+	return issues
+}
+
+// sourceOf returns the enabled source declaring the repository of an issue, nil if none
+func sourceOf(sources []aptSource, issue repoIssue) *aptSource {
+	for i, s := range sources {
+		if !s.Enabled {
+			continue
+		}
+		for _, uri := range s.URIs {
+			if strings.TrimSuffix(uri, "/") != issue.URI {
+				continue
+			}
+			for _, suite := range s.Suites {
+				if issue.Suite == "" || suite == issue.Suite {
+					return &sources[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// describeIssue returns the message of a repository problem
+func describeIssue(i repoIssue) string {
+	repo := strings.TrimSpace(i.URI + " " + i.Suite)
+	switch i.Kind {
+	case RepoUnreachable:
+		return getMessage("repo_unreachable", repo, i.Detail)
+	case RepoNoRelease:
+		return getMessage("repo_no_release", repo)
+	case RepoMissingKey:
+		return getMessage("repo_missing_key", repo, i.Detail)
+	case RepoExpiredKey:
+		return getMessage("repo_expired_key", repo, i.Detail)
+	case RepoLegacyKey:
+		return getMessage("repo_legacy_key", repo)
+	case RepoKeyExpiring:
+		return getMessage("repo_key_expiring", repo, i.Detail, i.Expires)
+	case RepoMissingKeyring:
+		return getMessage("repo_missing_keyring", repo, i.Detail)
+	}
+	return repo
+}
+
+// checkRepositories reports the repository problems of the output of "apt update",
+// running it when the run did not, and the signing keys expiring within keyDays.
+// The error lists the problems found.
+func checkRepositories(output string, keyDays int) ([]repoIssue, error) {
+	if output == "" {
+		var err error
+		if output, err = runCommand("sudo", "apt-get", "update"); err != nil && output == "" {
+			return nil, err
+		}
+	}
+	issues := parseAptUpdateIssues(output)
+	if _, err := os.Stat(aptTrustedGPG); err == nil && !hasIssue(issues, RepoLegacyKey) {
+		issues = append(issues, repoIssue{Kind: RepoLegacyKey, URI: aptTrustedGPG})
+	}
+
+	sources := loadSources()
+	issues = append(issues, checkKeys(sources, keyDays)...)
+	warnings := 0
+	for _, i := range issues {
+		msg := describeIssue(i)
+		if s := sourceOf(sources, i); s != nil {
+			msg += fmt.Sprintf(" (%s:%d)", s.File, s.Line)
+		}
+		if !brokenIssue(i) {
+			// Only reported: the legacy keyring still works and is present on most systems
+			printMessage(Blue, "  - "+msg)
+			continue
+		}
+		warnings++
+		printMessage(Yellow, "  - "+msg)
+	}
+	if hasIssue(issues, RepoLegacyKey) {
+		printMessage(Blue, getMessage("keys_migrate_hint", programName()))
+	}
+	if warnings > 0 {
+		return issues, errors.New(getMessage("repo_issues", warnings, programName()))
+	}
+	printMessage(Green, getMessage("repo_ok"))
+	return issues, nil
+}
+
+// hasIssue tells whether a problem of the given kind was found
+func hasIssue(issues []repoIssue, kind string) bool {
+	for _, i := range issues {
+		if i.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// brokenIssue tells whether a problem makes the repository unusable, as opposed
+// to a deprecation that still works and is not counted as a warning
+func brokenIssue(i repoIssue) bool {
+	return i.Kind != RepoLegacyKey
+}
+
+// sortBottomUp sorts sources by file, from the last line of each file up: an edit
+// adding a line (deb822 stanzas) then leaves the line numbers of the next ones valid
+func sortBottomUp(sources []aptSource) {
+	sort.Slice(sources, func(a, b int) bool {
+		if sources[a].File != sources[b].File {
+			return sources[a].File < sources[b].File
+		}
+		return sources[a].Line > sources[b].Line
+	})
+}
+
+// disableCommand returns the command disabling a source: the one-line entry is
+// commented out, the deb822 stanza gets "Enabled: no"
+func disableCommand(s aptSource) []string {
+	var script string
+	switch {
+	case !s.Deb822:
+		script = strconv.Itoa(s.Line) + `s/^/# disabled by uubu: /`
+	case s.enabledLine > 0:
+		script = strconv.Itoa(s.enabledLine) + `s/.*/Enabled: no/`
+	default:
+		script = strconv.Itoa(s.Line) + `i Enabled: no`
+	}
+	return []string{"sudo", "sed", "-i", "-e", script, s.File}
+}
+
+// setupRepos defines the repos command
+func setupRepos(fs *flag.FlagSet, config *Config) func([]string) int {
+	disable := fs.Bool("disable", false, getMessage("flag_repos_disable"))
+	yes := fs.Bool("yes", false, getMessage("flag_yes"))
+	asJSON := fs.Bool("json", false, getMessage("flag_json"))
+	migrate := fs.Bool("migrate-keys", false, getMessage("flag_migrate_keys"))
+	fs.IntVar(&config.KeyExpiryDays, "key-days", config.KeyExpiryDays, getMessage("flag_key_days"))
+
+	return func([]string) int {
+		if *migrate {
+			printMessage(Blue, getMessage("keys_migrating", aptTrustedGPG))
+			if err := migrateLegacyKeys(); err != nil {
+				printMessage(Red, getMessage("keys_migrate_error", err))
+				return ExitError
+			}
+			return ExitOK
+		}
+
+		printMessage(Blue, getMessage("repo_checking"))
+		issues, err := checkRepositories("", config.KeyExpiryDays)
+		if *asJSON {
+			if issues == nil {
+				issues = []repoIssue{}
+			}
+			return printJSON(issues)
+		}
+		if len(issues) == 0 || err == nil {
+			return ExitOK
+		}
+		if !*disable {
+			return ExitWarnings
+		}
+
+		// One source may be reported several times
+		sources := loadSources()
+		var broken []aptSource
+		done := make(map[string]bool)
+		for _, i := range issues {
+			s := sourceOf(sources, i)
+			if !brokenIssue(i) || s == nil {
+				continue
+			}
+			key := s.File + ":" + strconv.Itoa(s.Line)
+			if !done[key] {
+				done[key] = true
+				broken = append(broken, *s)
+			}
+		}
+		sortBottomUp(broken)
+
+		reader := bufio.NewReader(os.Stdin)
+		failed := false
+		for _, s := range broken {
+			where := fmt.Sprintf("%s:%d (%s)", s.File, s.Line, strings.Join(s.URIs, " "))
+			if !*yes && !confirm(reader, getMessage("repo_disable_prompt", where)) {
+				continue
+			}
+			cmd := disableCommand(s)
+			if _, err := runCommand(cmd[0], cmd[1:]...); err != nil {
+				printMessage(Red, getMessage("repo_disable_error", where, err))
+				failed = true
+				continue
+			}
+			printMessage(Green, getMessage("repo_disabled", where))
+		}
+		if failed {
+			return ExitError
+		}
+		return ExitOK
+	}
+}
+
+// confirm asks a yes/no question, no being the default
+func confirm(reader *bufio.Reader, question string) bool {
+	fmt.Print(question + " ")
+	response, err := reader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	response = strings.TrimSpace(strings.ToLower(response))
+	for _, yes := range strings.Split(getMessage("yes_answers"), ",") {
+		if response == strings.TrimSpace(yes) {
+			return true
+		}
+	}
+	return false
+}
+
+// repositoriesStep runs the repository check of an upgrade run on the output of
+// its "apt update", empty when the system step did not run it
+func repositoriesStep(config Config, result *RunResult, aptUpdate string) {
+	printMessage(Blue, getMessage("repo_checking"))
+	start := time.Now()
+	var issues []repoIssue
+	err := runStep(config, StepRepositories, func() (err error) {
+		issues, err = checkRepositories(aptUpdate, config.KeyExpiryDays)
+		return err
+	})
+	result.addStep(StepRepositories, start, err, false)
+	result.Repositories = issues
+}
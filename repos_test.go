@@ -0,0 +1,216 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSourcesList(t *testing.T) {
+	content := `# See sources.list(5)
+deb http://archive.ubuntu.com/ubuntu noble main restricted
+deb [arch=amd64 signed-by=/etc/apt/keyrings/docker.gpg] https://download.docker.com/linux/ubuntu noble stable
+# deb https://ppa.launchpadcontent.net/old/ppa/ubuntu jammy main
+deb-src http://archive.ubuntu.com/ubuntu noble main
+`
+	sources := parseSourcesList("/etc/apt/sources.list", content)
+	if len(sources) != 4 {
+		t.Fatalf("parseSourcesList() = %d sources, attendu 4", len(sources))
+	}
+
+	docker := sources[1]
+	if docker.Line != 3 || docker.URIs[0] != "https://download.docker.com/linux/ubuntu" || docker.Suites[0] != "noble" {
+		t.Errorf("Options mal ignorées: %+v", docker)
+	}
+	if sources[2].Enabled || sources[2].Suites[0] != "jammy" {
+		t.Errorf("Entrée commentée: %+v, attendue désactivée", sources[2])
+	}
+	if !sources[0].Enabled || sources[0].Deb822 {
+		t.Errorf("Entrée active: %+v", sources[0])
+	}
+}
+
+func TestParseDeb822Sources(t *testing.T) {
+	content := `Types: deb
+URIs: http://archive.ubuntu.com/ubuntu/
+Suites: noble noble-updates
+Components: main restricted
+Signed-By: /usr/share/keyrings/ubuntu-archive-keyring.gpg
+
+# Disabled third-party repository
+Types: deb
+URIs: https://repo.example.com/apt
+Suites: stable
+Enabled: no
+`
+	sources := parseDeb822Sources("/etc/apt/sources.list.d/ubuntu.sources", content)
+	if len(sources) != 2 {
+		t.Fatalf("parseDeb822Sources() = %d sources, attendu 2", len(sources))
+	}
+
+	if !reflect.DeepEqual(sources[0].Suites, []string{"noble", "noble-updates"}) || sources[0].Line != 1 || !sources[0].Enabled {
+		t.Errorf("Première strophe: %+v", sources[0])
+	}
+	if sources[1].Enabled || sources[1].Line != 8 || sources[1].enabledLine != 11 {
+		t.Errorf("Strophe désactivée: %+v", sources[1])
+	}
+}
+
+func TestParseAptUpdateIssues(t *testing.T) {
+	output := `Hit:1 http://archive.ubuntu.com/ubuntu noble InRelease
+Ign:2 https://ppa.launchpadcontent.net/old/ppa/ubuntu noble InRelease
+Err:3 https://ppa.launchpadcontent.net/old/ppa/ubuntu noble Release
+  404  Not Found [IP: 185.125.190.80 443]
+Err:4 http://repo.down.example/debian stable InRelease
+  Could not resolve 'repo.down.example'
+W: GPG error: https://repo.example.com/apt stable InRelease: The following signatures couldn't be verified because the public key is not available: NO_PUBKEY 1234ABCD5678EF00
+W: GPG error: https://packages.example.org/deb stable InRelease: The following signatures were invalid: EXPKEYSIG 0011223344556677 Example Packages
+E: The repository 'https://ppa.launchpadcontent.net/old/ppa/ubuntu noble Release' does not have a Release file.
+W: https://dl.google.com/linux/chrome/deb/dists/stable/InRelease: Key is stored in legacy trusted.gpg keyring (/etc/apt/trusted.gpg), see the DEPRECATION section in apt-key(8) for details.
+W: Failed to fetch http://repo.down.example/debian/dists/stable/InRelease  Could not resolve 'repo.down.example'
+W: Failed to fetch https://ppa.launchpadcontent.net/old/ppa/ubuntu/dists/noble/Release  404  Not Found
+W: Failed to fetch http://repo.down.example/debian/dists/stable/InRelease  Could not resolve 'repo.down.example'
+`
+	expected := []repoIssue{
+		{Kind: RepoMissingKey, URI: "https://repo.example.com/apt", Suite: "stable", Detail: "1234ABCD5678EF00"},
+		{Kind: RepoExpiredKey, URI: "https://packages.example.org/deb", Suite: "stable", Detail: "0011223344556677"},
+		{Kind: RepoNoRelease, URI: "https://ppa.launchpadcontent.net/old/ppa/ubuntu", Suite: "noble"},
+		{Kind: RepoLegacyKey, URI: "https://dl.google.com/linux/chrome/deb", Suite: "stable"},
+		{Kind: RepoUnreachable, URI: "http://repo.down.example/debian", Suite: "stable", Detail: "Could not resolve 'repo.down.example'"},
+	}
+
+	got := parseAptUpdateIssues(output)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseAptUpdateIssues() =\n%+v\nattendu\n%+v", got, expected)
+	}
+	if issues := parseAptUpdateIssues("Hit:1 http://archive.ubuntu.com/ubuntu noble InRelease\nReading package lists...\n"); len(issues) != 0 {
+		t.Errorf("Sortie saine: %+v, attendu aucun problème", issues)
+	}
+}
+
+func TestSourceOf(t *testing.T) {
+	sources := []aptSource{
+		{File: "a.list", Line: 1, URIs: []string{"https://repo.example.com/apt/"}, Suites: []string{"stable"}, Enabled: false},
+		{File: "b.list", Line: 2, URIs: []string{"https://repo.example.com/apt/"}, Suites: []string{"stable"}, Enabled: true},
+	}
+
+	s := sourceOf(sources, repoIssue{Kind: RepoMissingKey, URI: "https://repo.example.com/apt", Suite: "stable"})
+	if s == nil || s.File != "b.list" {
+		t.Errorf("sourceOf() = %+v, attendu b.list", s)
+	}
+	if s := sourceOf(sources, repoIssue{URI: "https://repo.example.com/apt", Suite: "testing"}); s != nil {
+		t.Errorf("Suite inconnue: sourceOf() = %+v, attendu nil", s)
+	}
+}
+
+func TestDisableCommand(t *testing.T) {
+	tests := []struct {
+		source   aptSource
+		expected string
+	}{
+		{aptSource{File: "/etc/apt/sources.list.d/ppa.list", Line: 3}, "3s/^/# disabled by uubu: /"},
+		{aptSource{File: "/etc/apt/sources.list.d/x.sources", Line: 5, Deb822: true}, "5i Enabled: no"},
+		{aptSource{File: "/etc/apt/sources.list.d/x.sources", Line: 5, Deb822: true, enabledLine: 9}, "9s/.*/Enabled: no/"},
+	}
+
+	for _, tt := range tests {
+		cmd := disableCommand(tt.source)
+		if cmd[len(cmd)-2] != tt.expected || cmd[len(cmd)-1] != tt.source.File {
+			t.Errorf("disableCommand(%+v) = %q, attendu le script %q", tt.source, strings.Join(cmd, " "), tt.expected)
+		}
+	}
+}
+
+func TestCheckRepositories_LegacyKeyring(t *testing.T) {
+	dir := t.TempDir()
+	for _, v := range []*string{&aptSourcesList, &aptSourcesDir, &aptTrustedGPG, &aptTrustedDir} {
+		previous := *v
+		defer func(v *string) { *v = previous }(v)
+	}
+	aptSourcesList = filepath.Join(dir, "sources.list")
+	aptSourcesDir = filepath.Join(dir, "sources.list.d")
+	aptTrustedDir = filepath.Join(dir, "trusted.gpg.d")
+	aptTrustedGPG = filepath.Join(dir, "trusted.gpg")
+	if err := os.WriteFile(aptTrustedGPG, []byte("key"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The legacy keyring alone is reported, not a warning
+	issues, err := checkRepositories("Hit:1 http://archive.ubuntu.com/ubuntu noble InRelease\n", 30)
+	if err != nil {
+		t.Errorf("checkRepositories() = %v, attendu aucune erreur", err)
+	}
+	if !hasIssue(issues, RepoLegacyKey) {
+		t.Errorf("checkRepositories() = %+v, attendu le trousseau trusted.gpg", issues)
+	}
+
+	output := "W: GPG error: https://repo.example.com/apt stable InRelease: The following signatures couldn't be verified because the public key is not available: NO_PUBKEY 1234ABCD5678EF00\n"
+	issues, err = checkRepositories(output, 30)
+	if err == nil || len(issues) != 2 {
+		t.Errorf("checkRepositories() = %+v, %v, attendu 2 problèmes et une erreur", issues, err)
+	}
+}
+
+func TestCheckRepositories_Locale(t *testing.T) {
+	dir := t.TempDir()
+	for _, v := range []*string{&aptSourcesList, &aptSourcesDir, &aptTrustedGPG, &aptTrustedDir} {
+		previous := *v
+		defer func(v *string) { *v = previous }(v)
+	}
+	aptSourcesList = filepath.Join(dir, "sources.list")
+	aptSourcesDir = filepath.Join(dir, "sources.list.d")
+	aptTrustedDir = filepath.Join(dir, "trusted.gpg.d")
+	aptTrustedGPG = filepath.Join(dir, "trusted.gpg")
+
+	// Fake "sudo apt-get update" answering in the language of the system
+	script := `#!/bin/sh
+if [ "$LC_ALL" = C ]; then
+	echo "W: GPG error: https://repo.example.com/apt stable InRelease: The following signatures couldn't be verified because the public key is not available: NO_PUBKEY 1234ABCD5678EF00"
+else
+	echo "W: Erreur de GPG : https://repo.example.com/apt stable InRelease : Les signatures suivantes n'ont pas pu être vérifiées car la clé publique n'est pas disponible : NO_PUBKEY 1234ABCD5678EF00"
+fi
+`
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("LC_ALL", "fr_FR.UTF-8")
+
+	issues, err := checkRepositories("", 30)
+	if err == nil || !hasIssue(issues, RepoMissingKey) {
+		t.Errorf("checkRepositories() = %+v, %v, attendu la clé manquante", issues, err)
+	}
+}
+
+func TestDisableCommand_SameFile(t *testing.T) {
+	if !commandExists("sed") {
+		t.Skip("sed absent")
+	}
+	content := "Types: deb\nURIs: https://a.example/apt\nSuites: stable\n\n" +
+		"Types: deb\nURIs: https://b.example/apt\nSuites: stable\n"
+	expected := "Enabled: no\nTypes: deb\nURIs: https://a.example/apt\nSuites: stable\n\n" +
+		"Enabled: no\nTypes: deb\nURIs: https://b.example/apt\nSuites: stable\n"
+	path := filepath.Join(t.TempDir(), "test.sources")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each inserted line shifts the stanzas below it
+	sources := parseDeb822Sources(path, content)
+	sortBottomUp(sources)
+	for _, s := range sources {
+		// Without sudo: the test file belongs to the user
+		cmd := disableCommand(s)[1:]
+		if output, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput(); err != nil {
+			t.Fatalf("%v: %v %s", cmd, err, output)
+		}
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != expected {
+		t.Errorf("Sources modifiées:\n%s\nattendu\n%s", data, expected)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the log file rotation
+const (
+	defaultLogMaxSize = 10 // MiB
+	defaultLogKeep    = 5
+)
+
+// LogOff in log_file disables the log file
+const LogOff = "off"
+
+// Log file of the runs of root
+var systemLogFile = "/var/log/uubu/uubu.log"
+
+// Native journald socket and identifier ("journalctl -t uubu")
+var journaldSocket = "/run/systemd/journal/socket"
+
+const journalIdentifier = "uubu"
+
+// Above this size the message sent to journald is truncated, the log file keeps it whole
+const maxJournalMessage = 48 * 1024
+
+// Priorities of syslog, used by journald
+const (
+	priorityErr     = 3
+	priorityWarning = 4
+	priorityInfo    = 6
+	priorityDebug   = 7
+)
+
+// priorityNames are the levels written in the log file
+var priorityNames = map[int]string{
+	priorityErr:     "ERROR",
+	priorityWarning: "WARN",
+	priorityInfo:    "INFO",
+	priorityDebug:   "DEBUG",
+}
+
+// runLog records a run in the log file and in journald
+type runLog struct {
+	mu      sync.Mutex
+	runID   string
+	step    string
+	path    string
+	file    *os.File
+	size    int64
+	maxSize int64
+	keep    int
+	journal *net.UnixConn
+}
+
+// activeLog is the log of the current run, nil outside a run
+var activeLog *runLog
+
+// logPath returns the log file: /var/log/uubu for root, next to the history otherwise
+func logPath(config Config) string {
+	if config.LogFile != "" {
+		return config.LogFile
+	}
+	if os.Geteuid() == 0 {
+		return systemLogFile
+	}
+	return filepath.Join(filepath.Dir(historyPath(config)), "uubu.log")
+}
+
+// underSystemd tells whether uubu runs in a systemd unit
+func underSystemd() bool {
+	return os.Getenv("INVOCATION_ID") != "" || os.Getenv("JOURNAL_STREAM") != ""
+}
+
+// startRunLog opens the log of a run. A log that cannot be opened is only reported.
+func startRunLog(config Config, result *RunResult) *runLog {
+	l := &runLog{
+		runID:   result.ID,
+		maxSize: int64(config.LogMaxSize) << 20,
+		keep:    config.LogKeep,
+	}
+	if config.LogFile != LogOff {
+		l.path = logPath(config)
+		if err := l.open(); err != nil {
+			printMessage(Yellow, getMessage("log_error", err))
+		}
+	}
+	if config.Journald && underSystemd() {
+		addr := &net.UnixAddr{Name: journaldSocket, Net: "unixgram"}
+		if conn, err := net.DialUnix("unixgram", nil, addr); err == nil {
+			l.journal = conn
+		}
+	}
+	if l.file == nil && l.journal == nil {
+		return nil
+	}
+
+	activeLog = l
+	message := fmt.Sprintf("run %s started (uubu %s, %s)", result.ID, version, result.Platform)
+	if result.ResumedFrom != "" {
+		message += ", resuming " + result.ResumedFrom
+	}
+	l.write(priorityInfo, message, "", nil)
+	return l
+}
+
+// open opens the log file for appending, rotating it first when it is full
+func (l *runLog) open() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return err
+	}
+	if info, err := os.Stat(l.path); err == nil && info.Size() >= l.maxSize {
+		if err := rotateLog(l.path, l.keep); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) // #nosec G304 -- path from the configuration
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+// rotateLog renames path to path.1, path.1 to path.2... keeping keep old files
+func rotateLog(path string, keep int) error {
+	if keep < 1 {
+		return os.Remove(path)
+	}
+	_ = os.Remove(fmt.Sprintf("%s.%d", path, keep))
+	for i := keep - 1; i >= 1; i-- {
+		old := fmt.Sprintf("%s.%d", path, i)
+		if err := os.Rename(old, fmt.Sprintf("%s.%d", path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(path, path+".1")
+}
+
+// formatLogEntry formats an entry of the log file, the lines of detail indented below it
+func formatLogEntry(now time.Time, priority int, runID, step, message, detail string) string {
+	if step == "" {
+		step = "-"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %-5s %s [%s] %s\n", now.Format(time.RFC3339), priorityNames[priority], runID, step, message)
+	for _, line := range splitLines(detail) {
+		sb.WriteString("    " + line + "\n")
+	}
+	return sb.String()
+}
+
+// encodeJournalFields encodes fields in the native journald protocol.
+// Values spanning several lines are sent with their length.
+func encodeJournalFields(fields [][2]string) []byte {
+	var buf bytes.Buffer
+	for _, f := range fields {
+		if !strings.Contains(f[1], "\n") {
+			buf.WriteString(f[0] + "=" + f[1] + "\n")
+			continue
+		}
+		buf.WriteString(f[0] + "\n")
+		_ = binary.Write(&buf, binary.LittleEndian, uint64(len(f[1])))
+		buf.WriteString(f[1] + "\n")
+	}
+	return buf.Bytes()
+}
+
+// write records an entry; detail is the command output, extra the journald fields
+func (l *runLog) write(priority int, message, detail string, extra [][2]string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		if l.size >= l.maxSize {
+			// Rotated during a long run as well
+			l.file.Close()
+			l.file = nil
+			if err := l.open(); err != nil {
+				return
+			}
+		}
+		n, _ := l.file.WriteString(formatLogEntry(time.Now(), priority, l.runID, l.step, message, detail))
+		l.size += int64(n)
+	}
+
+	if l.journal != nil {
+		text := message
+		if detail != "" {
+			text += "\n" + strings.TrimRight(detail, "\n")
+		}
+		if len(text) > maxJournalMessage {
+			text = text[:maxJournalMessage] + "\n[truncated]"
+		}
+		fields := [][2]string{
+			{"MESSAGE", text},
+			{"PRIORITY", strconv.Itoa(priority)},
+			{"SYSLOG_IDENTIFIER", journalIdentifier},
+			{"UUBU_RUN_ID", l.runID},
+		}
+		if l.step != "" {
+			fields = append(fields, [2]string{"UUBU_STEP", l.step})
+		}
+		_, _ = l.journal.Write(encodeJournalFields(append(fields, extra...)))
+	}
+}
+
+// message records a message shown to the user, its level given by its color
+func (l *runLog) message(color, message string) {
+	priority := priorityInfo
+	switch color {
+	case Red:
+		priority = priorityErr
+	case Yellow:
+		priority = priorityWarning
+	}
+	l.write(priority, message, "", nil)
+}
+
+// stepStarted records the start of a step, the following entries belonging to it
+func (l *runLog) stepStarted(step string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.step = step
+	l.mu.Unlock()
+	l.write(priorityInfo, "step started", "", nil)
+}
+
+// stepDone records the outcome of a step
+func (l *runLog) stepDone(step StepResult) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.step = step.Name
+	l.mu.Unlock()
+
+	priority := priorityInfo
+	message := fmt.Sprintf("step %s (%s)", step.Status, step.Duration.Round(time.Second))
+	switch step.Status {
+	case StatusFailed:
+		priority = priorityErr
+	case StatusWarning, StatusInterrupted:
+		priority = priorityWarning
+	case StatusSkipped:
+		message = "step skipped"
+	}
+	if step.Error != "" {
+		message += ": " + step.Error
+	}
+	l.write(priority, message, "", [][2]string{{"UUBU_STEP_STATUS", step.Status}})
+
+	l.mu.Lock()
+	l.step = ""
+	l.mu.Unlock()
+}
+
+// command records a command run with its whole output
+func (l *runLog) command(name string, args []string, output string, err error, elapsed time.Duration) {
+	if l == nil {
+		return
+	}
+	code := 0
+	priority := priorityDebug
+	if err != nil {
+		code = exitCode(err)
+		priority = priorityWarning
+	}
+	command := strings.Join(append([]string{name}, args...), " ")
+	message := fmt.Sprintf("$ %s: exit %d (%s)", command, code, elapsed.Round(time.Millisecond))
+	l.write(priority, message, output, [][2]string{
+		{"UUBU_COMMAND", command},
+		{"UUBU_EXIT_STATUS", strconv.Itoa(code)},
+	})
+}
+
+// finish records the outcome of the run and its upgraded packages
+func (l *runLog) finish(result *RunResult) {
+	if l == nil {
+		return
+	}
+	for _, line := range result.Upgraded {
+		name, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+		name, _, _ = strings.Cut(name, "/")
+		l.write(priorityInfo, "upgraded "+line, "", [][2]string{{"UUBU_PACKAGE", name}})
+	}
+
+	status, priority := "succeeded", priorityInfo
+	switch {
+	case result.Interrupted:
+		status, priority = "interrupted", priorityWarning
+	case !result.Success:
+		status, priority = "failed", priorityErr
+	}
+	message := fmt.Sprintf("run %s %s: %d packages upgraded, reboot required: %v",
+		result.ID, status, len(result.Upgraded), result.RebootRequired)
+	l.write(priority, message, strings.Join(result.Errors, "\n"), nil)
+}
+
+// close closes the log of the run
+func (l *runLog) close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if activeLog == l {
+		activeLog = nil
+	}
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	if l.journal != nil {
+		l.journal.Close()
+		l.journal = nil
+	}
+}
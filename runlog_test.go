@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uubu.log")
+	for i := 1; i <= 4; i++ {
+		if err := os.WriteFile(path, []byte(fmt.Sprint(i)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := rotateLog(path, 2); err != nil {
+			t.Fatalf("rotateLog() #%d: %v", i, err)
+		}
+	}
+
+	expected := map[string]string{path + ".1": "4", path + ".2": "3"}
+	for name, content := range expected {
+		if data, _ := os.ReadFile(name); string(data) != content {
+			t.Errorf("%s = %q, attendu %q", filepath.Base(name), data, content)
+		}
+	}
+	for _, name := range []string{path, path + ".3"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s existe, attendu absent", filepath.Base(name))
+		}
+	}
+}
+
+func TestFormatLogEntry(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	got := formatLogEntry(now, priorityWarning, "20261018-093000", "apt", "$ apt upgrade: exit 1 (2s)", "Reading package lists...\nE: Broken packages\n")
+	expected := "2026-10-18T09:30:00Z WARN  20261018-093000 [apt] $ apt upgrade: exit 1 (2s)\n" +
+		"    Reading package lists...\n" +
+		"    E: Broken packages\n"
+	if got != expected {
+		t.Errorf("formatLogEntry() = %q, attendu %q", got, expected)
+	}
+
+	got = formatLogEntry(now, priorityInfo, "20261018-093000", "", "run started", "")
+	if got != "2026-10-18T09:30:00Z INFO  20261018-093000 [-] run started\n" {
+		t.Errorf("formatLogEntry() sans étape = %q", got)
+	}
+}
+
+func TestEncodeJournalFields(t *testing.T) {
+	got := encodeJournalFields([][2]string{{"MESSAGE", "a\nb"}, {"UUBU_STEP", "apt"}})
+	expected := []byte("MESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\nUUBU_STEP=apt\n")
+	if !bytes.Equal(got, expected) {
+		t.Errorf("encodeJournalFields() = %q, attendu %q", got, expected)
+	}
+}
+
+func TestRunLog(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "journal.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("socket unixgram indisponible: %v", err)
+	}
+	defer conn.Close()
+	previous := journaldSocket
+	journaldSocket = socket
+	defer func() { journaldSocket = previous }()
+	t.Setenv("INVOCATION_ID", "test")
+
+	config := defaultConfig()
+	config.LogFile = filepath.Join(dir, "log", "uubu.log")
+	result := &RunResult{ID: "20261018-093000", Upgraded: []string{"curl/noble-updates 8.5.0-2ubuntu10.5 amd64"}}
+
+	l := startRunLog(config, result)
+	if l == nil || activeLog != l {
+		t.Fatal("startRunLog() = nil, attendu un journal actif")
+	}
+	l.stepStarted("apt")
+	failure := exec.Command("sh", "-c", "exit 1").Run()
+	l.command("apt", []string{"upgrade"}, "E: Broken packages\n", failure, time.Second)
+	result.addStep("apt", time.Now(), errors.New("apt upgrade failed"), true)
+	l.finish(result)
+	l.close()
+	if activeLog != nil {
+		t.Error("activeLog non nil après close()")
+	}
+
+	data, err := os.ReadFile(config.LogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"INFO  20261018-093000 [-] run 20261018-093000 started",
+		"INFO  20261018-093000 [apt] step started",
+		"[apt] $ apt upgrade: exit 1 (1s)\n    E: Broken packages\n",
+		"ERROR 20261018-093000 [apt] step failed (0s): apt upgrade failed",
+		"[-] upgraded curl/noble-updates",
+		"ERROR 20261018-093000 [-] run 20261018-093000 failed: 1 packages upgraded",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Fichier journal sans %q:\n%s", want, data)
+		}
+	}
+
+	var datagrams []string
+	buf := make([]byte, 65536)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			break
+		}
+		datagrams = append(datagrams, string(buf[:n]))
+	}
+	if len(datagrams) != 6 {
+		t.Fatalf("%d messages journald, attendu 6", len(datagrams))
+	}
+	for _, d := range datagrams {
+		if !strings.Contains(d, "SYSLOG_IDENTIFIER=uubu\n") || !strings.Contains(d, "UUBU_RUN_ID=20261018-093000\n") {
+			t.Errorf("Message journald sans identifiant ni run: %q", d)
+		}
+	}
+	if !strings.Contains(datagrams[2], "UUBU_STEP=apt\n") || !strings.Contains(datagrams[2], "UUBU_EXIT_STATUS=1\n") {
+		t.Errorf("Message de la commande = %q, attendu l'étape et le code de sortie", datagrams[2])
+	}
+	if !strings.Contains(datagrams[4], "UUBU_PACKAGE=curl\n") {
+		t.Errorf("Message du paquet = %q, attendu UUBU_PACKAGE=curl", datagrams[4])
+	}
+}
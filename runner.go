@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryConfig describes the retries of commands failing for a transient reason
+type RetryConfig struct {
+	// Total number of attempts, 1 disables the retries
+	Attempts int `json:"attempts"`
+	// Delay before the first retry in seconds, doubled at each retry up to MaxDelay
+	Delay    int `json:"delay"`
+	MaxDelay int `json:"max_delay"`
+}
+
+func defaultRetryConfig() RetryConfig {
+	return RetryConfig{Attempts: 3, Delay: 5, MaxDelay: 60}
+}
+
+// validate checks the retry settings
+func (c RetryConfig) validate() error {
+	if c.Attempts < 1 {
+		return fmt.Errorf("attempts must be at least 1")
+	}
+	if c.Delay < 0 || c.MaxDelay < 0 {
+		return fmt.Errorf("delays must not be negative")
+	}
+	return nil
+}
+
+// backoff returns the delay before the given retry (1 for the first one)
+func (c RetryConfig) backoff(retry int) time.Duration {
+	delay := time.Duration(c.Delay) * time.Second
+	max := time.Duration(c.MaxDelay) * time.Second
+	for i := 1; i < retry && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// Output of network and lock failures that a later attempt may not hit,
+// as opposed to package errors (broken dependencies, dpkg failures...)
+var transientPatterns = []string{
+	// Network
+	"temporary failure resolving",
+	"could not resolve",
+	"failed to fetch",
+	"connection timed out",
+	"connection failed",
+	"connection refused",
+	"connection reset",
+	"unable to connect",
+	"could not connect",
+	"network is unreachable",
+	"no route to host",
+	"i/o timeout",
+	"tls handshake timeout",
+	"timeout was reached",
+	"timeout exceeded",
+	"server misbehaving",
+	// Mirrors and stores
+	"hash sum mismatch",
+	"502 bad gateway",
+	"503 service unavailable",
+	"504 gateway",
+	"too many requests",
+	// Another package manager running (unattended-upgrades, packagekit)
+	"could not get lock",
+	"unable to acquire the dpkg frontend lock",
+}
+
+// isTransient tells whether a command output reports a transient failure
+func isTransient(output string) bool {
+	output = strings.ToLower(output)
+	for _, p := range transientPatterns {
+		if strings.Contains(output, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandPolicy applies to the commands run during a step
+type commandPolicy struct {
+	ctx   context.Context
+	retry RetryConfig
+}
+
+var (
+	policyMu sync.Mutex
+	policy   = commandPolicy{ctx: context.Background(), retry: RetryConfig{Attempts: 1}}
+)
+
+func currentPolicy() commandPolicy {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	return policy
+}
+
+func setPolicy(p commandPolicy) {
+	policyMu.Lock()
+	policy = p
+	policyMu.Unlock()
+}
+
+// stepTimeout returns the time allowed to a step, 0 for no limit
+func stepTimeout(config Config, step string) time.Duration {
+	return time.Duration(config.StepTimeouts[step]) * time.Second
+}
+
+// runStep runs fn with the timeout of the step and the retries of the configuration
+// applied to every command it starts
+func runStep(config Config, step string, fn func() error) error {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	timeout := stepTimeout(config, step)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	activeLog.stepStarted(step)
+	if d := activeDashboard; d != nil {
+		d.stepStarted(step)
+	}
+	previous := currentPolicy()
+	setPolicy(commandPolicy{ctx: ctx, retry: config.Retry})
+	defer setPolicy(previous)
+
+	err := fn()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &StepError{
+			Kind: KindTimeout,
+			Step: step,
+			Err:  fmt.Errorf("%s: %v", getMessage("step_timeout", step, timeout), err),
+		}
+	}
+	return err
+}
+
+// Time left to the children of a command to release its output once it ended
+const commandWaitDelay = 5 * time.Second
+
+// commandIO are the streams of a command besides its captured output
+type commandIO struct {
+	// Standard input, none when nil
+	stdin []byte
+	// Standard output kept apart from the captured output (the response of a plugin)
+	stdout io.Writer
+	// Variables added to the environment
+	env []string
+	// Output shown to the user as it comes (hooks, plugins), not only with --verbose
+	show bool
+	// Never retried: a second attempt may repeat side effects (hooks)
+	once bool
+}
+
+// runCommandOnce runs a command once, stopped when ctx ends
+func runCommandOnce(ctx context.Context, streams commandIO, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	// A child left behind by a killed command must not hold its output open
+	cmd.WaitDelay = commandWaitDelay
+	if streams.stdin != nil {
+		cmd.Stdin = bytes.NewReader(streams.stdin)
+	}
+	cmd.Stdout = streams.stdout
+	cmd.Env = append(os.Environ(), streams.env...)
+	if streams.env == nil {
+		// Output parsed in English (transient errors, dpkg lock, apt downloads);
+		// hooks and plugins, given their own variables, keep the locale of the
+		// user. sudo keeps LC_ALL.
+		cmd.Env = append(cmd.Env, "LC_ALL=C")
+	}
+	var output bytes.Buffer
+	var w io.Writer = &output
+	switch {
+	case activeDashboard != nil:
+		// Shown live in the log pane of the step
+		w = io.MultiWriter(&output, activeDashboard.commandOutput())
+	case streams.show && verbosity > LevelQuiet:
+		w = io.MultiWriter(&output, os.Stdout)
+	case verbosity >= LevelVerbose:
+		// On the standard error: the standard output may be JSON
+		w = io.MultiWriter(&output, os.Stderr)
+	}
+	debugCommand(name, args)
+	start := time.Now()
+	if err := startCommand(cmd, w); err != nil {
+		debugResult(name, err, time.Since(start))
+		activeLog.command(name, args, "", err, time.Since(start))
+		return "", err
+	}
+	err := waitCommand(cmd)
+	debugResult(name, err, time.Since(start))
+	activeLog.command(name, args, output.String(), err, time.Since(start))
+	return output.String(), err
+}
+
+// runWithPolicy runs a command with the policy of the current step,
+// retrying with exponential backoff while it fails for a transient reason
+func runWithPolicy(streams commandIO, name string, args ...string) (string, error) {
+	p := currentPolicy()
+	command := strings.Join(append([]string{name}, args...), " ")
+	for attempt := 1; ; attempt++ {
+		output, err := runCommandOnce(p.ctx, streams, name, args...)
+		if err != nil {
+			err = &CommandError{Command: command, Output: output, Err: err}
+		}
+		if err == nil || streams.once || attempt >= p.retry.Attempts || !isTransient(output) ||
+			p.ctx.Err() != nil || interrupted.Load() {
+			return output, err
+		}
+
+		if b, ok := streams.stdout.(*bytes.Buffer); ok {
+			// Only the output of the last attempt is read
+			b.Reset()
+		}
+		delay := p.retry.backoff(attempt)
+		printMessage(Yellow, getMessage("retrying", command, delay, attempt+1, p.retry.Attempts))
+		select {
+		case <-time.After(delay):
+		case <-p.ctx.Done():
+			return output, err
+		}
+	}
+}
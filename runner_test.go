@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected bool
+	}{
+		{"Err:1 http://archive.ubuntu.com jammy InRelease\n  Temporary failure resolving 'archive.ubuntu.com'", true},
+		{"E: Failed to fetch http://archive.ubuntu.com/ubuntu/pool/main/c/curl.deb  503  Service Unavailable", true},
+		{"E: Could not get lock /var/lib/dpkg/lock-frontend. It is held by process 1234 (unattended-upgr)", true},
+		{`error: cannot refresh: Get "https://api.snapcraft.io/v2/snaps/refresh": net/http: TLS handshake timeout`, true},
+		{"error: While pulling app/org.gimp.GIMP from remote flathub: Timeout was reached", true},
+		{"E: Unable to correct problems, you have held broken packages.", false},
+		{"dpkg: error processing package foo (--configure)", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isTransient(tt.output); got != tt.expected {
+			t.Errorf("isTransient(%q) = %v, attendu %v", tt.output, got, tt.expected)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	c := RetryConfig{Attempts: 5, Delay: 5, MaxDelay: 30}
+	expected := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, want := range expected {
+		if got := c.backoff(i + 1); got != want {
+			t.Errorf("backoff(%d) = %v, attendu %v", i+1, got, want)
+		}
+	}
+}
+
+func TestRetryConfig_Validate(t *testing.T) {
+	if err := defaultRetryConfig().validate(); err != nil {
+		t.Errorf("La configuration par défaut devrait être valide: %v", err)
+	}
+	if err := (RetryConfig{Attempts: 0}).validate(); err == nil {
+		t.Error("0 tentative devrait être refusé")
+	}
+	if err := (RetryConfig{Attempts: 2, Delay: -1}).validate(); err == nil {
+		t.Error("Un délai négatif devrait être refusé")
+	}
+}
+
+// counterScript fails with output until it has run fails times
+func counterScript(t *testing.T, fails int, output string) (script, counter string) {
+	t.Helper()
+	dir := t.TempDir()
+	counter = filepath.Join(dir, "count")
+	script = filepath.Join(dir, "flaky")
+	content := "#!/bin/sh\necho x >> " + counter + "\n" +
+		"[ $(wc -l < " + counter + ") -gt " + strconv.Itoa(fails) + " ] && exit 0\n" +
+		"echo '" + output + "'\nexit 100\n"
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return script, counter
+}
+
+func runs(t *testing.T, counter string) int {
+	t.Helper()
+	data, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "x")
+}
+
+func TestRunStep_Retries(t *testing.T) {
+	if !commandExists("sh") {
+		t.Skip("sh non installé")
+	}
+	config := defaultConfig()
+	config.Retry = RetryConfig{Attempts: 3, Delay: 0, MaxDelay: 0}
+
+	testCases := []struct {
+		name     string
+		fails    int
+		output   string
+		wantErr  bool
+		wantRuns int
+	}{
+		{"transient then success", 2, "Temporary failure resolving 'archive.ubuntu.com'", false, 3},
+		{"transient every time", 5, "Could not get lock /var/lib/dpkg/lock-frontend", true, 3},
+		{"package error", 5, "E: Unmet dependencies.", true, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			script, counter := counterScript(t, tc.fails, tc.output)
+			err := runStep(config, "apt", func() error {
+				_, err := runCommand(script)
+				return err
+			})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("runStep() erreur = %v, attendu erreur: %v", err, tc.wantErr)
+			}
+			if got := runs(t, counter); got != tc.wantRuns {
+				t.Errorf("%d exécutions, attendu %d", got, tc.wantRuns)
+			}
+		})
+	}
+
+	// Outside a step, no retry
+	script, counter := counterScript(t, 5, "Temporary failure resolving")
+	if _, err := runCommand(script); err == nil {
+		t.Error("runCommand() devrait échouer")
+	}
+	if got := runs(t, counter); got != 1 {
+		t.Errorf("%d exécutions hors étape, attendu 1", got)
+	}
+}
+
+func TestRunStep_Timeout(t *testing.T) {
+	if !commandExists("sleep") {
+		t.Skip("sleep non installé")
+	}
+	config := defaultConfig()
+	config.StepTimeouts = map[string]int{"snap": 1}
+
+	start := time.Now()
+	err := runStep(config, "snap", func() error {
+		_, err := runCommand("sleep", "10")
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "snap") {
+		t.Errorf("runStep() devrait signaler le délai dépassé, obtenu %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("La commande aurait dû être arrêtée au bout du délai")
+	}
+
+	// No timeout configured for the step
+	if err := runStep(config, "apt", func() error { _, err := runCommand("true"); return err }); err != nil {
+		t.Errorf("runStep() sans délai: %v", err)
+	}
+}
+
+func TestRunCommand_Locale(t *testing.T) {
+	if !commandExists("sh") {
+		t.Skip("sh non installé")
+	}
+	t.Setenv("LC_ALL", "fr_FR.UTF-8")
+
+	// Parsed output in the C locale
+	output, err := runCommand("sh", "-c", `echo "$LC_ALL"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(output); got != "C" {
+		t.Errorf("LC_ALL = %q, attendu C", got)
+	}
+
+	// Hooks and plugins keep the locale of the user
+	previous := verbosity
+	verbosity = LevelQuiet
+	defer func() { verbosity = previous }()
+	output, err = runWithPolicy(commandIO{env: []string{"UUBU_HOOK_PHASE=post-upgrade"}, show: true, once: true}, "sh", "-c", `echo "$LC_ALL"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(output); got != "fr_FR.UTF-8" {
+		t.Errorf("LC_ALL = %q, attendu fr_FR.UTF-8", got)
+	}
+}
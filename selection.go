@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default lifetime of the holds kept with --hold-deselected, in days
+const defaultHoldDays = 7
+
+// selectionItem is a pending update of the interactive selection
+type selectionItem struct {
+	Source   string
+	Package  string
+	Selected bool
+}
+
+// heldPackage is an update held back by uubu, released once Until is past
+type heldPackage struct {
+	Source  string    `json:"source"`
+	Package string    `json:"package"`
+	Until   time.Time `json:"until"`
+}
+
+// packageHolds are the commands holding back and releasing the updates of a source.
+// dnf and pacman have no hold command: their updates are not offered for selection.
+var packageHolds = map[string]struct{ hold, release []string }{
+	"apt":     {[]string{"sudo", "apt-mark", "hold"}, []string{"sudo", "apt-mark", "unhold"}},
+	"snap":    {[]string{"sudo", "snap", "refresh", "--hold"}, []string{"sudo", "snap", "refresh", "--unhold"}},
+	"flatpak": {[]string{"sudo", "flatpak", "mask"}, []string{"sudo", "flatpak", "mask", "--remove"}},
+}
+
+// holdsPath returns the file of the holds, next to the history
+func holdsPath(config Config) string {
+	return filepath.Join(filepath.Dir(historyPath(config)), "holds.json")
+}
+
+// isTerminal tells whether f is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// collectPending lists the pending updates of the enabled sources that can be held back.
+// The package lists are refreshed first so that the selection is up to date.
+func collectPending(config Config) ([]selectionItem, error) {
+	var items []selectionItem
+	for _, step := range updaterSteps(config) {
+		u := step.updater
+		if _, ok := packageHolds[u.Name()]; !ok || !step.enabled || !u.Detect() {
+			continue
+		}
+		if s, ok := u.(systemUpdater); ok {
+			if _, err := s.backend.refresh(); err != nil {
+				return nil, err
+			}
+		}
+		names, err := u.Pending()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			items = append(items, selectionItem{Source: u.Name(), Package: name, Selected: true})
+		}
+	}
+	return items, nil
+}
+
+// toggleItems applies a selection answer: numbers and ranges ("3 5-7") toggle
+// items, a source name toggles the whole source, "all" and "none" set every item
+func toggleItems(items []selectionItem, answer string) error {
+	for _, word := range strings.Fields(strings.ReplaceAll(answer, ",", " ")) {
+		switch word {
+		case "all", "none":
+			for i := range items {
+				items[i].Selected = word == "all"
+			}
+			continue
+		}
+		if _, ok := packageHolds[word]; ok {
+			// Deselects the source unless it is already fully deselected
+			selected := false
+			for _, item := range items {
+				selected = selected || (item.Source == word && item.Selected)
+			}
+			for i := range items {
+				if items[i].Source == word {
+					items[i].Selected = !selected
+				}
+			}
+			continue
+		}
+
+		first, last, isRange := strings.Cut(word, "-")
+		from, err := strconv.Atoi(first)
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(last)
+		}
+		if err != nil || from < 1 || to > len(items) || from > to {
+			return errors.New(getMessage("select_invalid", word))
+		}
+		for i := from - 1; i < to; i++ {
+			items[i].Selected = !items[i].Selected
+		}
+	}
+	return nil
+}
+
+// selectUpdates shows the checklist until an empty answer confirms the selection
+func selectUpdates(items []selectionItem) {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		source := ""
+		for i, item := range items {
+			if item.Source != source {
+				source = item.Source
+				printMessage(Blue, source)
+			}
+			mark := " "
+			if item.Selected {
+				mark = "x"
+			}
+			fmt.Printf("  %3d [%s] %s\n", i+1, mark, item.Package)
+		}
+		fmt.Print(getMessage("select_prompt") + " ")
+		answer, err := reader.ReadString('\n')
+		if strings.TrimSpace(answer) == "" || err != nil {
+			return
+		}
+		if err := toggleItems(items, answer); err != nil {
+			printMessage(Red, err.Error())
+		}
+		fmt.Println()
+	}
+}
+
+// loadHolds reads the holds of uubu, none when the file is missing
+func loadHolds(path string) []heldPackage {
+	var holds []heldPackage
+	if data, err := os.ReadFile(path); err == nil { // #nosec G304 -- path from the configuration
+		_ = json.Unmarshal(data, &holds)
+	}
+	return holds
+}
+
+// saveHolds writes the holds, removing the file when there is none left
+func saveHolds(path string, holds []heldPackage) error {
+	if len(holds) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(holds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// aptHeld returns the packages already held, by the administrator or by uubu
+func aptHeld() map[string]bool {
+	held := make(map[string]bool)
+	output, _ := runCommand("apt-mark", "showhold")
+	for _, name := range splitLines(output) {
+		held[name] = true
+	}
+	return held
+}
+
+// holdDeselected holds back the deselected updates until until and records
+// them. APT packages held by the administrator are left alone: releasing them
+// later would undo their hold.
+func holdDeselected(path string, items []selectionItem, until time.Time) ([]string, error) {
+	bySource := make(map[string][]string)
+	var sources []string
+	held := aptHeld()
+	for _, item := range items {
+		if item.Selected || (item.Source == "apt" && held[item.Package]) {
+			continue
+		}
+		if bySource[item.Source] == nil {
+			sources = append(sources, item.Source)
+		}
+		bySource[item.Source] = append(bySource[item.Source], item.Package)
+	}
+
+	holds := loadHolds(path)
+	var deselected []string
+	for _, source := range sources {
+		cmd := append(append([]string{}, packageHolds[source].hold...), bySource[source]...)
+		if _, err := runCommand(cmd[0], cmd[1:]...); err != nil {
+			return deselected, err
+		}
+		for _, name := range bySource[source] {
+			holds = append(holds, heldPackage{Source: source, Package: name, Until: until})
+			deselected = append(deselected, source+":"+name)
+		}
+		// Recorded at once: an interrupted run must still release them
+		if err := saveHolds(path, holds); err != nil {
+			return deselected, err
+		}
+	}
+	return deselected, nil
+}
+
+// releaseHolds releases the holds past their date, or all of them
+func releaseHolds(path string, all bool) {
+	now := time.Now()
+	var kept []heldPackage
+	bySource := make(map[string][]string)
+	var sources []string
+	for _, h := range loadHolds(path) {
+		if !all && h.Until.After(now) {
+			kept = append(kept, h)
+			continue
+		}
+		if bySource[h.Source] == nil {
+			sources = append(sources, h.Source)
+		}
+		bySource[h.Source] = append(bySource[h.Source], h.Package)
+	}
+
+	for _, source := range sources {
+		holds, ok := packageHolds[source]
+		if !ok {
+			continue
+		}
+		cmd := append(append([]string{}, holds.release...), bySource[source]...)
+		if _, err := runCommand(cmd[0], cmd[1:]...); err != nil {
+			printMessage(Yellow, getMessage("hold_release_error", source, err))
+			// Tried again at the next run
+			for _, name := range bySource[source] {
+				kept = append(kept, heldPackage{Source: source, Package: name, Until: now})
+			}
+			continue
+		}
+		printMessage(Blue, getMessage("hold_released", len(bySource[source]), source))
+	}
+	if err := saveHolds(path, kept); err != nil {
+		printMessage(Yellow, getMessage("hold_save_error", err))
+	}
+}
+
+// selectRun lets the user choose the updates of the run and holds back the others,
+// until the end of the run or, with keep, for the hold days of the configuration
+func selectRun(config Config, result *RunResult, keep bool) error {
+	path := holdsPath(config)
+	// The updates held by a previous selection are offered again
+	releaseHolds(path, true)
+
+	printMessage(Blue, getMessage("select_collecting"))
+	items, err := collectPending(config)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		printMessage(Green, getMessage("no_packages"))
+		return nil
+	}
+	selectUpdates(items)
+
+	until := time.Now()
+	if keep {
+		until = until.AddDate(0, 0, config.HoldDays)
+	}
+	result.Deselected, err = holdDeselected(path, items, until)
+	if len(result.Deselected) > 0 {
+		printMessage(Yellow, getMessage("select_held", len(result.Deselected)))
+	}
+	fmt.Println()
+	return err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testItems() []selectionItem {
+	return []selectionItem{
+		{"apt", "libc6", true},
+		{"apt", "openssl", true},
+		{"apt", "vim", true},
+		{"snap", "firefox", true},
+		{"flatpak", "org.gimp.GIMP", true},
+	}
+}
+
+// selected returns the selection marks of the items
+func selected(items []selectionItem) []bool {
+	marks := make([]bool, len(items))
+	for i, item := range items {
+		marks[i] = item.Selected
+	}
+	return marks
+}
+
+func TestToggleItems(t *testing.T) {
+	tests := []struct {
+		answer   string
+		expected []bool
+	}{
+		{"2", []bool{true, false, true, true, true}},
+		{"1-3", []bool{false, false, false, true, true}},
+		{"1, 5", []bool{false, true, true, true, false}},
+		{"snap", []bool{true, true, true, false, true}},
+		{"snap snap", []bool{true, true, true, true, true}},
+		{"2 apt", []bool{false, false, false, true, true}},
+		{"none 4", []bool{false, false, false, true, false}},
+		{"none all", []bool{true, true, true, true, true}},
+	}
+
+	for _, tt := range tests {
+		items := testItems()
+		if err := toggleItems(items, tt.answer); err != nil {
+			t.Errorf("toggleItems(%q): %v", tt.answer, err)
+			continue
+		}
+		if got := selected(items); !equalBools(got, tt.expected) {
+			t.Errorf("toggleItems(%q) = %v, attendu %v", tt.answer, got, tt.expected)
+		}
+	}
+
+	for _, answer := range []string{"0", "6", "3-2", "x", "dnf"} {
+		if err := toggleItems(testItems(), answer); err == nil {
+			t.Errorf("toggleItems(%q) devrait échouer", answer)
+		}
+	}
+}
+
+func equalBools(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHoldsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "holds.json")
+	if holds := loadHolds(path); holds != nil {
+		t.Errorf("Fichier absent: loadHolds() = %v, attendu nil", holds)
+	}
+
+	until := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	holds := []heldPackage{{Source: "apt", Package: "vim", Until: until}}
+	if err := saveHolds(path, holds); err != nil {
+		t.Fatal(err)
+	}
+	got := loadHolds(path)
+	if len(got) != 1 || got[0].Package != "vim" || !got[0].Until.Equal(until) {
+		t.Errorf("loadHolds() = %+v, attendu %+v", got, holds)
+	}
+
+	// Nothing past its date: nothing released, the file is kept
+	releaseHolds(path, false)
+	if got := loadHolds(path); len(got) != 1 {
+		t.Errorf("Après releaseHolds: %+v, attendu la retenue conservée", got)
+	}
+
+	if err := saveHolds(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Le fichier vide devrait être supprimé")
+	}
+}
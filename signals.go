@@ -0,0 +1,175 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// interrupted is set when SIGINT or SIGTERM was received during a run:
+// the step in progress finishes and the run stops before the next one
+var interrupted atomic.Bool
+
+// Subcommands changing the installed packages, by program.
+// An empty list means every invocation of the program.
+var transactionCommands = map[string][]string{
+	"apt":                {"upgrade", "dist-upgrade", "full-upgrade", "install", "reinstall", "remove", "purge", "autoremove"},
+	"apt-get":            {"upgrade", "dist-upgrade", "install", "reinstall", "remove", "purge", "autoremove"},
+	"dpkg":               {},
+	"nala":               {"upgrade", "full-upgrade", "install", "reinstall", "remove", "purge", "autoremove"},
+	"aptitude":           {"safe-upgrade", "full-upgrade", "install", "reinstall", "remove", "purge"},
+	"mintupdate-cli":     {"upgrade"},
+	"pop-upgrade":        {"update", "upgrade"},
+	"dnf":                {"upgrade", "distro-sync", "install", "reinstall", "remove", "autoremove"},
+	"pacman":             {"-Syu", "-S", "-U", "-R", "-Rns"},
+	"snap":               {"refresh", "install", "remove", "revert"},
+	"flatpak":            {"update", "install", "uninstall"},
+	"fwupdmgr":           {"update", "install"},
+	"timeshift":          {"--create"},
+	"do-release-upgrade": {},
+}
+
+// isTransaction tells whether a command changes the installed packages and
+// must not be interrupted halfway. "sudo" and its options are looked through.
+func isTransaction(name string, args []string) bool {
+	if filepath.Base(name) == "sudo" {
+		for len(args) > 0 && len(args[0]) > 0 && args[0][0] == '-' {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			return false
+		}
+		name, args = args[0], args[1:]
+	}
+
+	subcommands, ok := transactionCommands[filepath.Base(name)]
+	if !ok {
+		return false
+	}
+	for _, a := range args {
+		// Read-only variants such as "snap refresh --list"
+		if a == "--list" || a == "--dry-run" || a == "-s" || a == "--simulate" {
+			return false
+		}
+	}
+	if len(subcommands) == 0 {
+		return true
+	}
+	for _, a := range args {
+		for _, sub := range subcommands {
+			if a == sub {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Time left to a transaction after SIGTERM on a step timeout
+const transactionWaitDelay = 30 * time.Second
+
+// Command that may be interrupted, to which the signals are forwarded
+var (
+	interruptibleMu sync.Mutex
+	interruptible   *exec.Cmd
+)
+
+// startCommand starts cmd with its output captured, a standard output already
+// set being kept apart. Transactions run in their own process group so that
+// Ctrl-C in the terminal does not reach dpkg; the other commands receive the
+// forwarded signals.
+func startCommand(cmd *exec.Cmd, output io.Writer) error {
+	if cmd.Stdout == nil {
+		cmd.Stdout = output
+	}
+	cmd.Stderr = output
+	transaction := isTransaction(cmd.Path, cmd.Args[1:])
+	if transaction {
+		if filepath.Base(cmd.Path) == "sudo" {
+			sudoCredentials()
+		}
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		// On a step timeout, SIGTERM lets apt and dpkg exit cleanly before being killed
+		cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+		cmd.WaitDelay = transactionWaitDelay
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if !transaction {
+		interruptibleMu.Lock()
+		interruptible = cmd
+		interruptibleMu.Unlock()
+	}
+	return nil
+}
+
+// sudoCredentials asks the sudo password in the foreground when it is needed.
+// In its own process group, sudo cannot read the terminal: its prompt would
+// stop it with SIGTTIN and hang the run.
+func sudoCredentials() {
+	if exec.Command("sudo", "-n", "true").Run() == nil {
+		return
+	}
+	cmd := exec.Command("sudo", "-v")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stderr, os.Stderr
+	_ = cmd.Run()
+}
+
+// waitCommand waits for a command started by startCommand
+func waitCommand(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+	interruptibleMu.Lock()
+	if interruptible == cmd {
+		interruptible = nil
+	}
+	interruptibleMu.Unlock()
+	return err
+}
+
+// forwardSignal passes a signal to the interruptible command in progress, if any
+func forwardSignal(sig os.Signal) {
+	interruptibleMu.Lock()
+	defer interruptibleMu.Unlock()
+	if interruptible != nil && interruptible.Process != nil {
+		_ = interruptible.Process.Signal(sig)
+	}
+}
+
+// watchSignals catches SIGINT and SIGTERM until the returned function is called (once or more)
+func watchSignals() (stop func()) {
+	interrupted.Store(false)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if interrupted.Swap(true) {
+					printMessage(Yellow, getMessage("signal_again"))
+				} else {
+					printMessage(Yellow, getMessage("signal_received", sig))
+				}
+				forwardSignal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+	}
+}
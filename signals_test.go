@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsTransaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected bool
+	}{
+		{"sudo", []string{"apt", "upgrade", "-y"}, true},
+		{"sudo", []string{"-n", "apt", "dist-upgrade", "-y"}, true},
+		{"sudo", []string{"apt", "update"}, false},
+		{"apt", []string{"list", "--upgradable"}, false},
+		{"sudo", []string{"snap", "refresh"}, true},
+		{"snap", []string{"refresh", "--list"}, false},
+		{"flatpak", []string{"update", "-y"}, true},
+		{"flatpak", []string{"remote-ls", "--updates"}, false},
+		{"/usr/bin/dpkg", []string{"--configure", "-a"}, true},
+		{"sudo", []string{"timeshift", "--create", "--comments", "uubu"}, true},
+		{"sudo", []string{"fwupdmgr", "refresh", "--force"}, false},
+		{"sudo", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := isTransaction(tt.name, tt.args); got != tt.expected {
+			t.Errorf("isTransaction(%q, %v) = %v, attendu %v", tt.name, tt.args, got, tt.expected)
+		}
+	}
+}
+
+func TestStartCommand_ProcessGroup(t *testing.T) {
+	if !commandExists("dpkg") {
+		t.Skip("dpkg non installé")
+	}
+	var output bytes.Buffer
+	cmd := exec.CommandContext(context.Background(), "dpkg", "--version")
+	if err := startCommand(cmd, &output); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.SysProcAttr == nil || !cmd.SysProcAttr.Setpgid {
+		t.Error("Une transaction devrait tourner dans son propre groupe de processus")
+	}
+	if err := waitCommand(cmd); err != nil {
+		t.Errorf("waitCommand: %v", err)
+	}
+}
+
+func TestWatchSignals(t *testing.T) {
+	if !commandExists("sleep") {
+		t.Skip("sleep non installé")
+	}
+	stop := watchSignals()
+	defer stop()
+
+	// An interruptible command receives the forwarded signal
+	var output bytes.Buffer
+	cmd := exec.Command("sleep", "10")
+	if err := startCommand(cmd, &output); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- waitCommand(cmd) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("La commande aurait dû être interrompue")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Le signal n'a pas été transmis à la commande")
+	}
+
+	if !interrupted.Load() {
+		t.Error("L'interruption devrait être enregistrée")
+	}
+	stop()
+	stop()
+}
+
+func TestInterruptedStep(t *testing.T) {
+	defer interrupted.Store(false)
+	result := &RunResult{Success: true}
+	failure := errors.New("signal: interrupt")
+
+	interrupted.Store(false)
+	if result.interruptedStep("apt", time.Now(), failure) {
+		t.Error("Un échec sans signal ne devrait pas être une interruption")
+	}
+	interrupted.Store(true)
+	if result.interruptedStep("apt", time.Now(), nil) {
+		t.Error("Une étape terminée ne devrait pas être une interruption")
+	}
+	if !result.interruptedStep("apt", time.Now(), failure) {
+		t.Fatal("L'échec après un signal devrait être une interruption")
+	}
+
+	if len(result.Steps) != 1 || result.Steps[0].Status != StatusInterrupted {
+		t.Errorf("Étapes = %+v, attendu apt interrompue", result.Steps)
+	}
+	// Neither a failure nor a warning: the exit code is the one of the interruption
+	if !result.Success || len(result.Errors) != 0 {
+		t.Errorf("L'interruption ne devrait pas être un échec: %+v", result)
+	}
+	if code := runExitCode(result); code != ExitOK {
+		t.Errorf("runExitCode() = %d avant interruptRun, attendu %d", code, ExitOK)
+	}
+}
+
+func TestStartCommand_SudoPrompt(t *testing.T) {
+	if !commandExists("sh") {
+		t.Skip("sh non installé")
+	}
+	// Fake sudo: no cached credentials, every call recorded
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho \"$*\" >> " + calls + "\n[ \"$1\" = -n ] && exit 1\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(context.Background(), "sudo", "apt", "upgrade")
+	if err := startCommand(cmd, &output); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitCommand(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(calls)
+	if got, want := string(data), "-n true\n-v\napt upgrade\n"; got != want {
+		t.Errorf("Appels de sudo = %q, attendu %q", got, want)
+	}
+}
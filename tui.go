@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Step state shown while the step runs, before addStep records its status
+const StatusRunning = "running"
+
+// Lines kept per step, and delay between two redraws
+const (
+	dashboardLogLines = 500
+	dashboardRefresh  = 100 * time.Millisecond
+)
+
+// ANSI sequences of the dashboard: alternate screen, cursor and screen clearing
+const (
+	ansiAltScreen  = "\033[?1049h"
+	ansiMainScreen = "\033[?1049l"
+	ansiHideCursor = "\033[?25l"
+	ansiShowCursor = "\033[?25h"
+	ansiHome       = "\033[H\033[2J"
+	ansiBold       = "\033[1m"
+)
+
+// dashStep is a step of the dashboard
+type dashStep struct {
+	name     string
+	status   string
+	start    time.Time
+	duration time.Duration
+	err      string
+	logs     []string
+}
+
+// downloadProgress follows the downloads of an APT transaction
+type downloadProgress struct {
+	// Bytes announced by "Need to get", and fetched so far
+	total, done float64
+	current     string
+	finished    bool
+}
+
+// dashboard is the live terminal view of a run (--tui). While it runs,
+// the standard output of uubu is captured into the log pane of the current step.
+type dashboard struct {
+	mu       sync.Mutex
+	term     *os.File
+	title    string
+	steps    []*dashStep
+	current  *dashStep
+	download downloadProgress
+	reboot   bool
+	dirty    bool
+
+	pipe    *os.File
+	drained chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// activeDashboard is the dashboard of the run, nil in plain output
+var activeDashboard *dashboard
+
+// startRunDashboard starts the dashboard of an upgrade run, nil when the
+// standard output is not a terminal: the run then falls back to plain output
+func startRunDashboard(config Config, result *RunResult) *dashboard {
+	if !isTerminal(os.Stdout) {
+		return nil
+	}
+	plan := []string{StepInternet, StepSnapshot}
+	for _, step := range updaterSteps(config) {
+		plan = append(plan, step.updater.Name())
+	}
+	plan = append(plan, StepRepositories, StepFirmware)
+
+	d := startDashboard(getMessage("tui_title", version, result.Hostname, result.Platform), plan)
+	if d != nil {
+		d.setReboot(rebootRequired())
+	}
+	return d
+}
+
+var (
+	// "Need to get 45.2 MB of archives."
+	aptNeedLine = regexp.MustCompile(`Need to get ([\d.,]+ [kMG]?B)(?:/[\d.,]+ [kMG]?B)? of archives`)
+	// "Get:5 http://archive.ubuntu.com/ubuntu noble-updates/main amd64 libc6 amd64 2.39-0ubuntu8.3 [3,263 kB]"
+	aptGetLine = regexp.MustCompile(`^Get:\d+ \S+ \S+ (?:\S+ )?(\S+) \S+ \S+ \[([\d.,]+ [kMG]?B)\]`)
+	// "Fetched 45.2 MB in 5s (9,040 kB/s)"
+	aptFetchedLine = regexp.MustCompile(`^Fetched [\d.,]+ [kMG]?B in`)
+	ansiSequence   = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+)
+
+// parseSize reads an APT size such as "3,263 kB" in bytes, 0 when unknown
+func parseSize(size string) float64 {
+	number, unit, _ := strings.Cut(size, " ")
+	value, err := strconv.ParseFloat(strings.ReplaceAll(number, ",", ""), 64)
+	if err != nil {
+		return 0
+	}
+	switch unit {
+	case "kB":
+		value *= 1e3
+	case "MB":
+		value *= 1e6
+	case "GB":
+		value *= 1e9
+	}
+	return value
+}
+
+// formatSize formats bytes the way APT does
+func formatSize(bytes float64) string {
+	switch {
+	case bytes >= 1e9:
+		return fmt.Sprintf("%.1f GB", bytes/1e9)
+	case bytes >= 1e6:
+		return fmt.Sprintf("%.1f MB", bytes/1e6)
+	case bytes >= 1e3:
+		return fmt.Sprintf("%.0f kB", bytes/1e3)
+	}
+	return fmt.Sprintf("%.0f B", bytes)
+}
+
+// update follows a line of APT output, telling whether it was about downloads
+func (p *downloadProgress) update(line string) bool {
+	if m := aptNeedLine.FindStringSubmatch(line); m != nil {
+		*p = downloadProgress{total: parseSize(m[1])}
+		return true
+	}
+	if m := aptGetLine.FindStringSubmatch(line); m != nil {
+		p.current = m[1]
+		p.done += parseSize(m[2])
+		p.finished = false
+		return true
+	}
+	if aptFetchedLine.MatchString(line) {
+		p.finished = true
+		p.current = ""
+		p.done = p.total
+		return true
+	}
+	return false
+}
+
+// String describes the progress, empty when nothing was downloaded
+func (p downloadProgress) String() string {
+	switch {
+	case p.current == "" && !p.finished:
+		return ""
+	case p.finished:
+		return getMessage("tui_download_done", formatSize(p.done))
+	case p.total > 0:
+		percent := 100 * p.done / p.total
+		if percent > 100 {
+			percent = 100
+		}
+		return getMessage("tui_download", formatSize(p.done), formatSize(p.total), percent, p.current)
+	}
+	return getMessage("tui_download", formatSize(p.done), "?", 0.0, p.current)
+}
+
+// terminalSize returns the columns and lines of the terminal, 80x24 when unknown
+func terminalSize(f *os.File) (int, int) {
+	var size struct{ rows, cols, x, y uint16 }
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	if errno != 0 || size.cols == 0 || size.rows == 0 {
+		return 80, 24
+	}
+	return int(size.cols), int(size.rows)
+}
+
+// startDashboard takes over the terminal until stop is called
+func startDashboard(title string, plan []string) *dashboard {
+	d := &dashboard{
+		term:    os.Stdout,
+		title:   title,
+		drained: make(chan struct{}),
+		done:    make(chan struct{}),
+		dirty:   true,
+	}
+	for _, name := range plan {
+		d.steps = append(d.steps, &dashStep{name: name})
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil
+	}
+	d.pipe = writer
+	os.Stdout = writer
+	go d.capture(reader)
+
+	fmt.Fprint(d.term, ansiAltScreen+ansiHideCursor)
+	go d.refresh()
+	activeDashboard = d
+	return d
+}
+
+// capture logs the captured standard output, line by line
+func (d *dashboard) capture(r io.Reader) {
+	defer close(d.drained)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		d.log(scanner.Text())
+	}
+}
+
+// refresh redraws the dashboard when it changed
+func (d *dashboard) refresh() {
+	ticker := time.NewTicker(dashboardRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+			d.mu.Lock()
+			if d.dirty {
+				width, height := terminalSize(d.term)
+				fmt.Fprint(d.term, ansiHome+d.render(width, height, time.Now()))
+				d.dirty = false
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+// stepNamed returns the step called name, added at the end when not planned
+func (d *dashboard) stepNamed(name string) *dashStep {
+	for _, s := range d.steps {
+		if s.name == name {
+			return s
+		}
+	}
+	s := &dashStep{name: name}
+	d.steps = append(d.steps, s)
+	return s
+}
+
+// stepStarted marks a step as running: the next lines go to its pane
+func (d *dashboard) stepStarted(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.stepNamed(name)
+	s.status = StatusRunning
+	s.start = time.Now()
+	d.current = s
+	d.download = downloadProgress{}
+	d.dirty = true
+}
+
+// stepDone records the outcome of a step
+func (d *dashboard) stepDone(step StepResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.stepNamed(step.Name)
+	s.status = step.Status
+	s.duration = step.Duration
+	s.err = step.Error
+	d.dirty = true
+}
+
+// setReboot shows the pending reboot indicator
+func (d *dashboard) setReboot(required bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reboot = required
+	d.dirty = true
+}
+
+// log adds a line to the pane of the current step, following the downloads
+func (d *dashboard) log(line string) {
+	line = strings.TrimRight(ansiSequence.ReplaceAllString(line, ""), " \r")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.download.update(line)
+	s := d.current
+	if s == nil {
+		// Before the first step: header lines
+		s = d.stepNamed("")
+		d.current = s
+	}
+	s.logs = append(s.logs, line)
+	if len(s.logs) > dashboardLogLines {
+		s.logs = s.logs[len(s.logs)-dashboardLogLines:]
+	}
+	d.dirty = true
+}
+
+// commandOutput returns a writer feeding command output to the log pane
+func (d *dashboard) commandOutput() io.Writer {
+	return &lineWriter{emit: d.log}
+}
+
+// lineWriter calls emit for each complete line written, "\r" ending a line too
+type lineWriter struct {
+	emit    func(string)
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := strings.IndexAny(string(w.partial), "\r\n")
+		if i < 0 {
+			return len(p), nil
+		}
+		if line := string(w.partial[:i]); strings.TrimSpace(line) != "" {
+			w.emit(line)
+		}
+		w.partial = w.partial[i+1:]
+	}
+}
+
+// statusSymbol returns the symbol of a step state
+func statusSymbol(status string) string {
+	switch status {
+	case StatusOK:
+		return colorize(Green, "✔")
+	case StatusWarning:
+		return colorize(Yellow, "!")
+	case StatusFailed:
+		return colorize(Red, "✘")
+	case StatusSkipped:
+		return "-"
+	case StatusInterrupted:
+		return colorize(Yellow, "■")
+	case StatusRunning:
+		return colorize(Blue, "▶")
+	}
+	return "·"
+}
+
+// truncate cuts s to width runes
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width < 1 {
+		return ""
+	}
+	if len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return s
+}
+
+// render draws the dashboard: title and reboot indicator, steps, log pane of
+// the current step and download progress
+func (d *dashboard) render(width, height int, now time.Time) string {
+	var sb strings.Builder
+	title := truncate(d.title, width)
+	if d.reboot {
+		indicator := getMessage("tui_reboot")
+		title = truncate(d.title, width-len([]rune(indicator))-1)
+		title += strings.Repeat(" ", width-len([]rune(title))-len([]rune(indicator))) + colorize(Yellow, indicator)
+	}
+	sb.WriteString(colorize(ansiBold, title) + "\n")
+
+	var steps []*dashStep
+	for _, s := range d.steps {
+		if s.name != "" {
+			steps = append(steps, s)
+		}
+	}
+	for _, s := range steps {
+		duration := ""
+		switch {
+		case s.status == StatusRunning:
+			duration = now.Sub(s.start).Round(time.Second).String()
+		case s.duration > 0:
+			duration = s.duration.Round(100 * time.Millisecond).String()
+		}
+		line := truncate(fmt.Sprintf("%-14s %8s  %s", s.name, duration, s.err), width-4)
+		sb.WriteString(" " + statusSymbol(s.status) + "  " + line + "\n")
+	}
+
+	name := ""
+	var logs []string
+	if d.current != nil {
+		name, logs = d.current.name, d.current.logs
+	}
+	sb.WriteString(truncate("── "+name+" "+strings.Repeat("─", width), width) + "\n")
+
+	// Title, steps, two rules and the progress line
+	pane := height - len(steps) - 4
+	if pane < 1 {
+		pane = 1
+	}
+	if len(logs) > pane {
+		logs = logs[len(logs)-pane:]
+	}
+	for _, line := range logs {
+		sb.WriteString(truncate(line, width) + "\n")
+	}
+	for i := len(logs); i < pane; i++ {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(truncate(strings.Repeat("─", width), width) + "\n")
+	sb.WriteString(truncate(d.download.String(), width))
+	return sb.String()
+}
+
+// summaryTable formats the final table of the steps
+func (d *dashboard) summaryTable() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%-14s %-8s %9s  %s\n", getMessage("tui_step"), getMessage("tui_status"),
+		getMessage("tui_duration"), getMessage("tui_error")))
+	for _, s := range d.steps {
+		if s.name == "" {
+			continue
+		}
+		status := s.status
+		if status == "" || status == StatusRunning {
+			status = "-"
+		}
+		duration := ""
+		if s.duration > 0 {
+			duration = s.duration.Round(100 * time.Millisecond).String()
+		}
+		sb.WriteString(fmt.Sprintf("%-14s %-8s %9s  %s\n", s.name, status, duration, s.err))
+	}
+	return sb.String()
+}
+
+// stop gives the terminal back and prints the summary table.
+// It may be called several times.
+func (d *dashboard) stop() {
+	if d == nil {
+		return
+	}
+	d.once.Do(func() {
+		activeDashboard = nil
+		os.Stdout = d.term
+		d.pipe.Close()
+		<-d.drained
+		close(d.done)
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		fmt.Fprint(d.term, ansiShowCursor+ansiMainScreen)
+		fmt.Fprint(d.term, d.summaryTable())
+		if d.reboot {
+			printMessage(Yellow, getMessage("tui_reboot"))
+		}
+		fmt.Fprintln(d.term)
+	})
+}
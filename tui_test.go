@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size     string
+		expected float64
+	}{
+		{"3,263 kB", 3263000},
+		{"45.2 MB", 45200000},
+		{"1.5 GB", 1500000000},
+		{"512 B", 512},
+		{"n/a", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseSize(tt.size); got != tt.expected {
+			t.Errorf("parseSize(%q) = %v, attendu %v", tt.size, got, tt.expected)
+		}
+	}
+}
+
+func TestDownloadProgress(t *testing.T) {
+	var p downloadProgress
+	if p.String() != "" {
+		t.Errorf("Sans téléchargement: %q, attendu vide", p.String())
+	}
+
+	lines := []string{
+		"Need to get 4,000 kB of archives.",
+		"Get:1 http://archive.ubuntu.com/ubuntu noble-updates/main amd64 libc6 amd64 2.39-0ubuntu8.3 [3,000 kB]",
+	}
+	for _, line := range lines {
+		if !p.update(line) {
+			t.Errorf("update(%q) = false, attendu true", line)
+		}
+	}
+	if p.current != "libc6" || p.done != 3e6 || p.total != 4e6 {
+		t.Errorf("Progression = %+v", p)
+	}
+	if !strings.Contains(p.String(), "75%") {
+		t.Errorf("String() = %q, attendu 75%%", p.String())
+	}
+
+	if p.update("Setting up libc6:amd64 (2.39-0ubuntu8.3) ...") {
+		t.Errorf("Ligne sans téléchargement reconnue")
+	}
+	p.update("Fetched 4,000 kB in 2s (2,000 kB/s)")
+	if !p.finished || p.current != "" {
+		t.Errorf("Après Fetched: %+v", p)
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := &lineWriter{emit: func(line string) { lines = append(lines, line) }}
+	for _, chunk := range []string{"Reading pack", "age lists...\nProgress: 10%\rProgress: 20%\r\n", "\nlast"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{"Reading package lists...", "Progress: 10%", "Progress: 20%"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("Lignes = %q, attendu %q", lines, expected)
+	}
+}
+
+func TestDashboardRender(t *testing.T) {
+	d := &dashboard{title: "uubu test — host"}
+	for _, name := range []string{"internet", "apt", "snap"} {
+		d.steps = append(d.steps, &dashStep{name: name})
+	}
+	d.stepDone(StepResult{Name: "internet", Status: StatusOK, Duration: 300 * time.Millisecond})
+	d.stepStarted("apt")
+	for i := 0; i < 50; i++ {
+		d.log("\033[0;34mline " + strings.Repeat("x", i) + "\033[0m")
+	}
+	d.setReboot(true)
+
+	screen := d.render(60, 20, time.Now())
+	lines := strings.Split(screen, "\n")
+	if len(lines) != 20 {
+		t.Errorf("render() = %d lignes, attendu 20", len(lines))
+	}
+	if !strings.Contains(lines[0], getMessage("tui_reboot")) {
+		t.Errorf("Indicateur de redémarrage absent: %q", lines[0])
+	}
+	for _, want := range []string{"internet", "apt", "snap", "line " + strings.Repeat("x", 49)[:40]} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("render() ne contient pas %q", want)
+		}
+	}
+	if strings.Contains(screen, "line x\n") {
+		t.Errorf("Les anciennes lignes devraient défiler hors du panneau")
+	}
+
+	table := d.summaryTable()
+	if !strings.Contains(table, "internet") || !strings.Contains(table, StatusOK) || strings.Count(table, "\n") != 4 {
+		t.Errorf("summaryTable() =\n%s", table)
+	}
+}
+
+func TestDashboardDownloads_Locale(t *testing.T) {
+	if !commandExists("sh") {
+		t.Skip("sh non installé")
+	}
+	t.Setenv("LC_ALL", "fr_FR.UTF-8")
+	d := &dashboard{}
+	activeDashboard = d
+	defer func() { activeDashboard = nil }()
+
+	// APT answering in the language of the system
+	script := `if [ "$LC_ALL" = C ]; then echo "Need to get 4,000 kB of archives."; else echo "Il est nécessaire de prendre 4 000 ko dans les archives."; fi`
+	if _, err := runCommand("sh", "-c", script); err != nil {
+		t.Fatal(err)
+	}
+	if d.download.total != 4e6 {
+		t.Errorf("Téléchargement = %+v, attendu 4 MB à télécharger", d.download)
+	}
+}
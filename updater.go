@@ -0,0 +1,180 @@
+package main
+
+// Updater is a source of updates handled by the upgrade command
+type Updater interface {
+	// Name identifies the updater in the steps, the reports and the metrics
+	Name() string
+	// Detect tells whether the package manager is installed
+	Detect() bool
+	// Pending lists the updates waiting to be installed
+	Pending() ([]string, error)
+	// Update installs the updates and returns the upgraded packages
+	Update() ([]string, error)
+	// Cleanup removes what the update left behind
+	Cleanup() error
+}
+
+// updaterStep is an updater of the run, enabled or not by the configuration
+type updaterStep struct {
+	updater Updater
+	enabled bool
+	// A failure of a critical updater aborts the run
+	critical bool
+}
+
+// Built-in steps besides the updaters, also the names of their journal
+// entries and step_timeouts keys
+const (
+	StepInternet       = "internet"
+	StepSnapshot       = "snapshot"
+	StepRepositories   = "repositories"
+	StepFirmware       = "firmware"
+	StepUpToDate       = "up-to-date"
+	StepReleaseUpgrade = "release-upgrade"
+)
+
+// builtinStepNames returns the names of the built-in steps, which plugins may not take
+func builtinStepNames() map[string]bool {
+	names := map[string]bool{
+		StepInternet:       true,
+		StepSnapshot:       true,
+		StepRepositories:   true,
+		StepFirmware:       true,
+		StepUpToDate:       true,
+		StepReleaseUpgrade: true,
+	}
+	// Every system backend: the one of this host is only known at run time
+	for _, b := range []packageBackend{aptBackend{}, dnfBackend{}, pacmanBackend{}} {
+		names[b.name()] = true
+	}
+	for _, u := range []Updater{snapUpdater{}, flatpakUpdater{}} {
+		names[u.Name()] = true
+	}
+	return names
+}
+
+// updaterSteps lists the updaters of a run in execution order:
+// the built-in ones, then the plugins
+func updaterSteps(config Config) []updaterStep {
+	steps := []updaterStep{
+		{systemUpdater{systemBackend(detectDistro(), config.AptFrontend), config.DistUpgrade, config.Changelog}, true, true},
+		{snapUpdater{}, config.UpdateSnap, false},
+		{flatpakUpdater{}, config.UpdateFlatpak, false},
+	}
+	for _, p := range discoverPlugins(config.PluginDir) {
+		steps = append(steps, updaterStep{p, true, false})
+	}
+	return steps
+}
+
+// updateOutcome is what an update leaves to the rest of the run
+type updateOutcome struct {
+	upgraded []string
+	// Changelogs shown before upgrading
+	changelogs []PackageChangelog
+	// Output of the package list refresh, read by the repository check
+	refreshOutput string
+}
+
+// outcomeUpdater is an updater returning more than its upgraded packages
+type outcomeUpdater interface {
+	UpdateOutcome() (updateOutcome, error)
+}
+
+// runUpdater updates then cleans up. A failed cleanup is only reported.
+func runUpdater(u Updater) (updateOutcome, error) {
+	var outcome updateOutcome
+	var err error
+	if ou, ok := u.(outcomeUpdater); ok {
+		outcome, err = ou.UpdateOutcome()
+	} else {
+		outcome.upgraded, err = u.Update()
+	}
+	if err != nil {
+		return outcome, err
+	}
+	if err := u.Cleanup(); err != nil {
+		printMessage(Yellow, getMessage("cleanup_error", u.Name(), err))
+	}
+	return outcome, nil
+}
+
+// systemUpdater upgrades the packages of the distribution with its package manager
+type systemUpdater struct {
+	backend     packageBackend
+	distUpgrade bool
+	// Changelog preview mode of the upgradable packages
+	changelog string
+}
+
+func (u systemUpdater) Name() string { return u.backend.name() }
+
+func (u systemUpdater) Detect() bool { return commandExists(u.backend.command()) }
+
+func (u systemUpdater) Pending() ([]string, error) {
+	lines, err := u.backend.upgradable()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, line := range lines {
+		names = append(names, u.backend.packageName(line))
+	}
+	return names, nil
+}
+
+func (u systemUpdater) Update() ([]string, error) {
+	outcome, err := u.UpdateOutcome()
+	return outcome.upgraded, err
+}
+
+func (u systemUpdater) UpdateOutcome() (updateOutcome, error) {
+	return updateSystem(u.backend, u.distUpgrade, u.changelog)
+}
+
+func (u systemUpdater) Cleanup() error {
+	cleanupSystem(u.backend)
+	return nil
+}
+
+// snapUpdater refreshes the snaps
+type snapUpdater struct{}
+
+func (snapUpdater) Name() string { return "snap" }
+
+func (snapUpdater) Detect() bool { return commandExists("snap") }
+
+func (snapUpdater) Pending() ([]string, error) {
+	output, err := runCommand("snap", "refresh", "--list")
+	if err != nil {
+		return nil, err
+	}
+	return parseSnapRefreshList(output), nil
+}
+
+func (snapUpdater) Update() ([]string, error) {
+	return nil, updateSnap()
+}
+
+func (snapUpdater) Cleanup() error { return nil }
+
+// flatpakUpdater updates the Flatpak applications and runtimes
+type flatpakUpdater struct{}
+
+func (flatpakUpdater) Name() string { return "flatpak" }
+
+func (flatpakUpdater) Detect() bool { return commandExists("flatpak") }
+
+func (flatpakUpdater) Pending() ([]string, error) {
+	output, err := runCommand("flatpak", "remote-ls", "--updates", "--columns=application")
+	if err != nil {
+		return nil, err
+	}
+	return parseFlatpakUpdates(output), nil
+}
+
+func (flatpakUpdater) Update() ([]string, error) {
+	return nil, updateFlatpak()
+}
+
+func (flatpakUpdater) Cleanup() error { return nil }